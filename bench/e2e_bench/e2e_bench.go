@@ -35,6 +35,12 @@ type Post struct {
 	Created  time.Time `json:"created"`
 }
 
+// FeedResp represents one page of a user's feed returned by the API.
+type FeedResp struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor"`
+}
+
 func main() {
 	// CLI flags
 	var serverAddr string
@@ -71,11 +77,11 @@ func main() {
 	users := make([]UserResp, 0, U)
 	for i := 0; i < U; i++ {
 		// Generate unique username
-		payload := map[string]string{"username": fmt.Sprintf("user-%d-%d", i, time.Now().UnixNano())}
+		payload := map[string]string{"username": fmt.Sprintf("user-%d-%d", i, time.Now().UnixNano()), "password": "bench-password"}
 		b, _ := json.Marshal(payload)
 
 		// Send POST request to create user
-		resp, err := client.Post(serverAddr+"/users", "application/json", bytes.NewReader(b))
+		resp, err := client.Post(serverAddr+"/v1/users", "application/json", bytes.NewReader(b))
 		if err != nil {
 			fmt.Printf("create user error: %v\n", err)
 			os.Exit(1)
@@ -109,7 +115,7 @@ func main() {
 			}
 			payload := map[string]string{"followee_id": followee.UserID}
 			b, _ := json.Marshal(payload)
-			req, _ := http.NewRequestWithContext(ctx, "POST", serverAddr+"/follow", bytes.NewReader(b))
+			req, _ := http.NewRequestWithContext(ctx, "POST", serverAddr+"/v1/follow", bytes.NewReader(b))
 			req.Header.Set("Content-Type", "application/json")
 			req.Header.Set("Authorization", "Bearer "+u.Token)
 
@@ -148,7 +154,7 @@ func main() {
 			reqBody := PostReq{Body: body}
 			b, _ := json.Marshal(reqBody)
 
-			req, _ := http.NewRequestWithContext(ctx, "POST", serverAddr+"/posts", bytes.NewReader(b))
+			req, _ := http.NewRequestWithContext(ctx, "POST", serverAddr+"/v1/posts", bytes.NewReader(b))
 			req.Header.Set("Content-Type", "application/json")
 			req.Header.Set("Authorization", "Bearer "+author.Token)
 
@@ -191,7 +197,7 @@ func main() {
 
 				// Poll the feed until post appears or timeout
 				for time.Now().Before(deadline) {
-					req, _ := http.NewRequestWithContext(ctx, "GET", serverAddr+"/feed", nil)
+					req, _ := http.NewRequestWithContext(ctx, "GET", serverAddr+"/v1/feed", nil)
 					req.Header.Set("Authorization", "Bearer "+token)
 					resp, err := client.Do(req)
 					if err != nil {
@@ -199,15 +205,15 @@ func main() {
 						continue
 					}
 
-					var posts []Post
-					if err := json.NewDecoder(resp.Body).Decode(&posts); err != nil {
+					var feed FeedResp
+					if err := json.NewDecoder(resp.Body).Decode(&feed); err != nil {
 						resp.Body.Close()
 						time.Sleep(200 * time.Millisecond)
 						continue
 					}
 					resp.Body.Close()
 
-					for _, pp := range posts {
+					for _, pp := range feed.Posts {
 						if pp.ID == pr.PostID {
 							lat := time.Since(pr.Created).Seconds() * 1000
 							latMu.Lock()
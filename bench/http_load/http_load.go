@@ -63,10 +63,10 @@ func main() {
 	fmt.Printf("Creating %d users...\n", concurrency)
 	users := make([]UserResp, concurrency)
 	for i := 0; i < concurrency; i++ {
-		payload := map[string]string{"username": fmt.Sprintf("load-user-%d-%d", i, time.Now().UnixNano())}
+		payload := map[string]string{"username": fmt.Sprintf("load-user-%d-%d", i, time.Now().UnixNano()), "password": "bench-password"}
 		b, _ := json.Marshal(payload)
 
-		resp, err := client.Post(server+"/users", "application/json", bytes.NewReader(b))
+		resp, err := client.Post(server+"/v1/users", "application/json", bytes.NewReader(b))
 		if err != nil {
 			panic(fmt.Sprintf("failed to create user: %v", err))
 		}
@@ -105,7 +105,7 @@ func main() {
 				body := PostReq{Body: fmt.Sprintf("load test post %d", time.Now().UnixNano())}
 				b, _ := json.Marshal(body)
 
-				req, _ := http.NewRequestWithContext(context.Background(), "POST", server+"/posts", bytes.NewReader(b))
+				req, _ := http.NewRequestWithContext(context.Background(), "POST", server+"/v1/posts", bytes.NewReader(b))
 				req.Header.Set("Content-Type", "application/json")
 				req.Header.Set("Authorization", "Bearer "+user.Token)
 
@@ -2,13 +2,13 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"example.com/cassandrafeed/internal/events"
 	"github.com/gocql/gocql"
 	"github.com/segmentio/kafka-go"
 )
@@ -65,8 +65,8 @@ func main() {
 					Created:  time.Now(),
 				}
 
-				// Marshal post to JSON
-				v, err := json.Marshal(p)
+				// Wrap post in a versioned event envelope
+				v, err := events.Encode(events.TypePostCreated, "bench", p)
 				if err != nil {
 					atomic.AddUint64(&failCount, 1)
 					fmt.Printf("marshal error: %v\n", err)
@@ -75,7 +75,7 @@ func main() {
 
 				// Add message to batch
 				batch = append(batch, kafka.Message{
-					Key:   []byte("post_created"),
+					Key:   []byte(events.TypePostCreated),
 					Value: v,
 				})
 
@@ -0,0 +1,59 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/logger"
+)
+
+var logg = logger.New()
+
+// Replay re-injects dead-lettered messages into the main topic with their
+// failure metadata stripped. It stops once the dead-letter topic has been idle
+// for idleTimeout or the context is cancelled, and returns how many messages
+// were replayed. A DLQ offset is committed only after the message was
+// written back, so an interrupted replay never loses messages.
+func Replay(ctx context.Context, reader appkafka.KafkaReader, writer appkafka.KafkaWriter, idleTimeout time.Duration) (int, error) {
+	if idleTimeout <= 0 {
+		idleTimeout = 10 * time.Second
+	}
+
+	logg.Info("deadletter", "Replaying dead-letter messages into the main topic")
+
+	replayed := 0
+	lastSeen := time.Now()
+	for {
+		if ctx.Err() != nil || time.Since(lastSeen) > idleTimeout {
+			break
+		}
+
+		readCtx, cancel := context.WithTimeout(ctx, idleTimeout)
+		msg, err := reader.FetchMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return replayed, fmt.Errorf("read dead-letter message: %w", err)
+		}
+		if len(msg.Value) == 0 {
+			continue
+		}
+		lastSeen = time.Now()
+
+		if err := writer.WriteMessages(appkafka.NewReplayMessage(msg)); err != nil {
+			return replayed, fmt.Errorf("replay dead-letter message: %w", err)
+		}
+		if err := reader.CommitMessages(context.Background(), msg); err != nil {
+			return replayed, fmt.Errorf("commit dead-letter offset: %w", err)
+		}
+		replayed++
+	}
+
+	logg.Info("deadletter", "Replayed dead-letter messages", "messages", replayed)
+	return replayed, nil
+}
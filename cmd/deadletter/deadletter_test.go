@@ -0,0 +1,83 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	appkafka "example.com/cassandrafeed/internal/broker"
+	"github.com/segmentio/kafka-go"
+)
+
+// queueReader returns queued messages, then blocks until the context ends.
+type queueReader struct {
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (q *queueReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	return q.FetchMessage(ctx)
+}
+
+func (q *queueReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	q.committed = append(q.committed, msgs...)
+	return nil
+}
+
+func (q *queueReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	if len(q.messages) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := q.messages[0]
+	q.messages = q.messages[1:]
+	return msg, nil
+}
+
+func (q *queueReader) Close() error { return nil }
+
+func TestReplay_StripsFailureHeaders(t *testing.T) {
+	original := kafka.Message{
+		Key:     []byte("post_created"),
+		Value:   []byte(`{"id":"1"}`),
+		Headers: []kafka.Header{{Key: "trace", Value: []byte("abc")}},
+	}
+	dead := appkafka.NewFailureMessage(original, 3, time.Time{}, errors.New("boom"))
+
+	reader := &queueReader{messages: []kafka.Message{dead}}
+	writer := &appkafka.MockKafka{}
+
+	n, err := Replay(context.Background(), reader, writer, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 replayed message, got %d", n)
+	}
+
+	written := writer.Written()
+	if len(written) != 1 || string(written[0].Value) != string(original.Value) {
+		t.Fatalf("unexpected replayed messages: %+v", written)
+	}
+	if appkafka.RetryCount(written[0]) != 0 || appkafka.HeaderValue(written[0], appkafka.HeaderError) != "" {
+		t.Fatalf("failure headers not stripped: %+v", written[0].Headers)
+	}
+	if appkafka.HeaderValue(written[0], "trace") != "abc" {
+		t.Fatalf("expected unrelated headers to be kept: %+v", written[0].Headers)
+	}
+	if len(reader.committed) != 1 {
+		t.Fatalf("expected replayed message to be committed, got %d commits", len(reader.committed))
+	}
+}
+
+func TestReplay_WriteFailure(t *testing.T) {
+	reader := &queueReader{messages: []kafka.Message{{Value: []byte(`{}`)}}}
+
+	if _, err := Replay(context.Background(), reader, &appkafka.MockKafkaFail{}, 20*time.Millisecond); err == nil {
+		t.Fatalf("expected error from MockKafkaFail")
+	}
+	if len(reader.committed) != 0 {
+		t.Fatalf("expected no commit when the replay write fails")
+	}
+}
@@ -0,0 +1,157 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/logger"
+	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var logg = logger.New()
+
+// cursorLag is how far before the last delivered event a shard is read
+// again. Events are ordered by time UUIDs generated on the servers, so an
+// event written late, or by a server whose clock lags, can sort before
+// events already delivered; it is still published within this window.
+const cursorLag = time.Minute
+
+// Relay publishes pending outbox events from Cassandra to Kafka.
+type Relay struct {
+	store        store.StoreInterface
+	writer       appkafka.KafkaWriter
+	pollInterval time.Duration
+	batchSize    int
+
+	// cursors holds per shard the creation time of the last delivered
+	// event, loaded from the store on first use
+	cursors map[int]time.Time
+}
+
+// New creates a new outbox Relay using pre-initialized dependencies.
+func New(store store.StoreInterface, writer appkafka.KafkaWriter, pollInterval time.Duration, batchSize int) *Relay {
+	if pollInterval <= 0 {
+		pollInterval = 500 * time.Millisecond
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &Relay{
+		store:        store,
+		writer:       writer,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+		cursors:      make(map[int]time.Time),
+	}
+}
+
+// Run polls the outbox until the context is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	logg.Info("relay", "Starting outbox relay", "poll_interval", r.pollInterval.String())
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx); err != nil {
+			logg.Error("relay", "Outbox flush failed", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			logg.Info("relay", "Outbox relay stopped gracefully")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes one batch of pending events from every shard and returns
+// how many were delivered. An event is removed from the outbox only after
+// Kafka accepted it, so a crash in between results in a redelivery, not a loss.
+// Each shard is read from shortly before its last delivered event, so the
+// tombstones of older deliveries are not read again.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	delivered := 0
+	for shard := 0; shard < store.OutboxShards; shard++ {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+
+		cursor, err := r.cursor(shard)
+		if err != nil {
+			return delivered, fmt.Errorf("read outbox cursor of shard %d: %w", shard, err)
+		}
+		since := cursor
+		if !since.IsZero() {
+			since = since.Add(-cursorLag)
+		}
+
+		events, err := r.store.GetPendingOutbox(shard, since, r.batchSize)
+		if err != nil {
+			return delivered, fmt.Errorf("read outbox shard %d: %w", shard, err)
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		// Each message continues the trace of the request that queued it
+		msgs := make([]kafka.Message, 0, len(events))
+		spans := make([]trace.Span, 0, len(events))
+		for _, e := range events {
+			msg := kafka.Message{
+				Key:   []byte(e.Key),
+				Value: e.Payload,
+			}
+			spanCtx, span := tracing.StartKafka(tracing.Extract(ctx, e.TraceContext), trace.SpanKindProducer, "publish", msg)
+			tracing.InjectKafka(spanCtx, &msg)
+			msgs = append(msgs, msg)
+			spans = append(spans, span)
+		}
+
+		err = r.writer.WriteMessages(msgs...)
+		for _, span := range spans {
+			tracing.End(span, err)
+		}
+		if err != nil {
+			return delivered, fmt.Errorf("publish outbox shard %d: %w", shard, err)
+		}
+
+		for _, e := range events {
+			if err := r.store.MarkOutboxDelivered(e); err != nil {
+				return delivered, fmt.Errorf("mark outbox event delivered: %w", err)
+			}
+			delivered++
+		}
+
+		if last := events[len(events)-1].Created; last.After(cursor) {
+			if err := r.store.SaveOutboxCursor(shard, last); err != nil {
+				return delivered, fmt.Errorf("save outbox cursor of shard %d: %w", shard, err)
+			}
+			r.cursors[shard] = last
+		}
+	}
+
+	if delivered > 0 {
+		logg.Info("relay", "Published outbox events to Kafka", "events", delivered)
+	}
+	return delivered, nil
+}
+
+// cursor returns the creation time of the last event delivered from shard.
+func (r *Relay) cursor(shard int) (time.Time, error) {
+	if cursor, ok := r.cursors[shard]; ok {
+		return cursor, nil
+	}
+	cursor, err := r.store.GetOutboxCursor(shard)
+	if err != nil {
+		return time.Time{}, err
+	}
+	r.cursors[shard] = cursor
+	return cursor, nil
+}
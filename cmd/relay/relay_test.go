@@ -0,0 +1,153 @@
+package relay
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/events"
+	"example.com/cassandrafeed/internal/models"
+	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
+)
+
+// ---------- Positive test ----------
+
+func TestRelay_PublishesAndMarksDelivered(t *testing.T) {
+	mockStore := store.NewMock()
+
+	authorID, _ := mockStore.CreateUser("author", "")
+	followerID, _ := mockStore.CreateUser("follower", "")
+	mockStore.CreateFollow(followerID, authorID)
+
+	post := models.Post{
+		ID:       "100",
+		AuthorID: authorID,
+		Body:     "Hello from the outbox",
+		Created:  time.Now(),
+	}
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
+
+	if err := mockStore.AddPostWithOutbox(post, models.OutboxEvent{Key: events.TypePostCreated, Payload: data}); err != nil {
+		t.Fatalf("AddPostWithOutbox failed: %v", err)
+	}
+
+	mockKafka := &appkafka.MockKafka{Store: mockStore}
+	r := New(mockStore, mockKafka, 0, 0)
+
+	n, err := r.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 delivered event, got %d", n)
+	}
+	if len(mockStore.Outbox) != 0 {
+		t.Fatalf("expected empty outbox, got %+v", mockStore.Outbox)
+	}
+
+	feed, _ := mockStore.GetFeed(followerID, 10)
+	if len(feed) != 1 || feed[0].Body != post.Body {
+		t.Fatalf("feed not updated correctly, got: %+v", feed)
+	}
+
+	// A second flush has nothing left to publish
+	if n, _ := r.Flush(context.Background()); n != 0 {
+		t.Fatalf("expected no events on second flush, got %d", n)
+	}
+}
+
+// the published message continues the trace stored with the outbox event
+func TestRelay_PropagatesTraceContext(t *testing.T) {
+	tracing.Init(context.Background(), "", 1, "test")
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	mockStore := store.NewMock()
+	post := models.Post{ID: "200", AuthorID: "author", Body: "traced", Created: time.Now()}
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
+	event := models.OutboxEvent{
+		Key:          events.TypePostCreated,
+		Payload:      data,
+		TraceContext: map[string]string{"traceparent": "00-" + traceID + "-00f067aa0ba902b7-01"},
+	}
+	if err := mockStore.AddPostWithOutbox(post, event); err != nil {
+		t.Fatalf("AddPostWithOutbox failed: %v", err)
+	}
+
+	mockKafka := &appkafka.MockKafka{}
+	if _, err := New(mockStore, mockKafka, 0, 0).Flush(context.Background()); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if len(mockKafka.WrittenMessages) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(mockKafka.WrittenMessages))
+	}
+	if got := appkafka.HeaderValue(mockKafka.WrittenMessages[0], "traceparent"); !strings.Contains(got, traceID) {
+		t.Fatalf("expected traceparent of trace %s, got %q", traceID, got)
+	}
+}
+
+// each shard is read from shortly before its last delivered event, so late
+// events within the lag are still published while older ranges are skipped
+func TestRelay_ReadsFromCursor(t *testing.T) {
+	mockStore := store.NewMock()
+	post := models.Post{ID: "300", AuthorID: "author", Body: "cursor", Created: time.Now()}
+	delivered := time.Now()
+	if err := mockStore.AddPostWithOutbox(post, models.OutboxEvent{Key: post.ID, Payload: []byte(`{}`), Created: delivered}); err != nil {
+		t.Fatalf("AddPostWithOutbox failed: %v", err)
+	}
+	shard := mockStore.Outbox[0].Shard
+
+	mockKafka := &appkafka.MockKafka{}
+	if _, err := New(mockStore, mockKafka, 0, 0).Flush(context.Background()); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if got := mockStore.Cursors[shard]; !got.Equal(delivered) {
+		t.Fatalf("expected the cursor at the delivered event, got %v", got)
+	}
+
+	// A late event within the lag is published, one before it is not read
+	late := models.OutboxEvent{Key: post.ID, Payload: []byte(`{}`), Created: delivered.Add(-cursorLag / 2)}
+	stale := models.OutboxEvent{Key: post.ID, Payload: []byte(`{}`), Created: delivered.Add(-2 * cursorLag)}
+	mockStore.AddPostWithOutbox(post, late)
+	mockStore.AddPostWithOutbox(post, stale)
+
+	// A restarted relay loads the cursor from the store
+	n, err := New(mockStore, mockKafka, 0, 0).Flush(context.Background())
+	if err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+	if n != 1 || len(mockStore.Outbox) != 1 || !mockStore.Outbox[0].Created.Equal(stale.Created) {
+		t.Fatalf("expected only the late event to be published, got %d, outbox %+v", n, mockStore.Outbox)
+	}
+}
+
+// ---------- Negative tests ----------
+
+func TestRelay_StoreFailure(t *testing.T) {
+	r := New(&store.MockStoreFail{}, &appkafka.MockKafka{Store: store.NewMock()}, 0, 0)
+
+	if _, err := r.Flush(context.Background()); err == nil {
+		t.Fatalf("expected error from store GetPendingOutbox")
+	}
+}
+
+func TestRelay_StopsOnContextCancel(t *testing.T) {
+	r := New(store.NewMock(), &appkafka.MockKafkaFail{}, 10*time.Millisecond, 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("relay did not stop after context cancellation")
+	}
+}
@@ -0,0 +1,16 @@
+package server
+
+import (
+	"net/http"
+
+	"example.com/cassandrafeed/internal/apierr"
+	"example.com/cassandrafeed/internal/store"
+)
+
+// The store's sentinel errors as API errors. They are registered here, so
+// apierr does not depend on the store.
+func init() {
+	apierr.Register(store.ErrInvalidCursor, http.StatusBadRequest, apierr.CodeInvalidRequest, "invalid cursor")
+	apierr.Register(store.ErrUsernameTaken, http.StatusConflict, apierr.CodeConflict, "username already taken")
+	apierr.Register(store.ErrAlreadyFollowing, http.StatusConflict, apierr.CodeConflict, "already following this user")
+}
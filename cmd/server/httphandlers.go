@@ -1,83 +1,188 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"os"
 	"strconv"
+	"sync"
 	"time"
 
+	"example.com/cassandrafeed/internal/apierr"
+	"example.com/cassandrafeed/internal/events"
+	"example.com/cassandrafeed/internal/logger"
 	"example.com/cassandrafeed/internal/middleware"
 	"example.com/cassandrafeed/internal/models"
-	"github.com/golang-jwt/jwt/v5"
+	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
+	"github.com/gocql/gocql"
 	"github.com/google/uuid"
-	"github.com/segmentio/kafka-go"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // --- HTTP Handlers ---
 
-// createUserHandler handles POST requests to create a new user.
-// Expects JSON body: {"username": "example"}
-// Returns JSON response: {"user_id": <id>}
+// Password length bounds; bcrypt ignores everything past 72 bytes.
+const (
+	minPasswordLen = 8
+	maxPasswordLen = 72
+)
+
+// passwordCost is the bcrypt cost used to hash new passwords.
+var passwordCost = bcrypt.DefaultCost
+
+// credentialsRequest is the body of registration and login requests.
+type credentialsRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// createUserHandler registers a new user.
+// Expects JSON body: {"username": "example", "password": "..."}
+// Returns 201 with JSON response: {"user_id": "...", "token": "..."},
+// or 409 if the username is already taken.
 func (s *Server) createUserHandler(w http.ResponseWriter, r *http.Request) {
-	type req struct{ Username string }
-	var body req
+	var body credentialsRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		logg.Error("http/users", "Invalid request body", err)
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		logg.ErrorContext(r.Context(), "http/users", "Invalid request body", err)
+		apierr.Write(w, r, apierr.BadRequest("invalid request body"))
 		return
 	}
 	defer r.Body.Close()
 
 	if len(body.Username) == 0 || len(body.Username) > 50 {
-		logg.Info("http/users", "Invalid username length")
-		http.Error(w, "username must be 1-50 characters", http.StatusBadRequest)
+		logg.InfoContext(r.Context(), "http/users", "Invalid username length")
+		apierr.Write(w, r, apierr.BadRequest("username must be 1-50 characters"))
+		return
+	}
+	if len(body.Password) < minPasswordLen || len(body.Password) > maxPasswordLen {
+		logg.InfoContext(r.Context(), "http/users", "Invalid password length")
+		apierr.Write(w, r, apierr.BadRequest("password must be 8-72 characters"))
 		return
 	}
 
-	userID, err := s.store.GetUserIDByUsername(body.Username)
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), passwordCost)
 	if err != nil {
-		logg.Error("http/users", "Failed to query existing username", err)
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		logg.ErrorContext(r.Context(), "http/users", "Failed to hash password", err)
+		apierr.Write(w, r, err)
 		return
 	}
 
-	if userID == "" {
-		userID, err = s.store.CreateUser(body.Username)
-		if err != nil {
-			logg.Error("http/users", "Failed to create user", err)
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		logg.Info("http/users", "User created successfully with user_id="+userID)
-	} else {
-		logg.Info("http/users", "User already exists, returning existing user_id="+userID)
+	userID, err := s.storeFor(r).CreateUser(body.Username, string(hash))
+	if errors.Is(err, store.ErrUsernameTaken) {
+		logg.InfoContext(r.Context(), "http/users", "Registration with a taken username")
+		apierr.Write(w, r, err)
+		return
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/users", "Failed to create user", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	logg.InfoContext(r.Context(), "http/users", "User created successfully", logger.UserRef(userID))
+
+	s.startSession(w, r, http.StatusCreated, userID)
+}
+
+// loginHandler exchanges a username and password for an access token.
+// Expects JSON body: {"username": "example", "password": "..."}
+// Returns JSON response: {"user_id": "...", "token": "..."}, or 401 if the
+// username or password is wrong.
+func (s *Server) loginHandler(w http.ResponseWriter, r *http.Request) {
+	var body credentialsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Invalid request body", err)
+		apierr.Write(w, r, apierr.BadRequest("invalid request body"))
+		return
+	}
+	defer r.Body.Close()
+
+	creds, err := s.storeFor(r).GetCredentials(body.Username)
+	if err != nil && !errors.Is(err, gocql.ErrNotFound) {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to get credentials", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	// Unknown users, and accounts registered before passwords were required,
+	// are checked against a dummy hash so response times do not reveal which
+	// usernames exist.
+	hash, known := creds.PasswordHash, err == nil && creds.PasswordHash != ""
+	if !known {
+		hash = dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(body.Password)) != nil || !known {
+		logg.InfoContext(r.Context(), "http/sessions", "Failed login attempt")
+		apierr.Write(w, r, apierr.Unauthorized("invalid username or password"))
+		return
 	}
 
-	secret := []byte(os.Getenv("JWT_SECRET"))
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
-	})
-	tokenStr, err := token.SignedString(secret)
+	logg.InfoContext(r.Context(), "http/sessions", "User logged in", logger.UserRef(creds.UserID))
+	s.startSession(w, r, http.StatusOK, creds.UserID)
+}
+
+// dummyPasswordHash is compared against when a login names no known user.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy-password"), passwordCost)
+	return string(hash)
+})
+
+// getUserHandler returns a user by ID.
+// Path: /users/{id}
+// Returns JSON response: {"id": "...", "username": "..."}
+func (s *Server) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	user, err := s.storeFor(r).GetUser(userID)
+	if errors.Is(err, gocql.ErrNotFound) {
+		apierr.Write(w, r, apierr.NotFound("user not found"))
+		return
+	}
 	if err != nil {
-		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		logg.ErrorContext(r.Context(), "http/users", "Failed to get user", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// lookupUserHandler resolves a username to its user.
+// Query parameters: ?username=example
+// Returns JSON response: {"id": "...", "username": "..."}
+func (s *Server) lookupUserHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.URL.Query().Get("username")
+	if username == "" {
+		apierr.Write(w, r, apierr.BadRequest("username query parameter is required"))
 		return
 	}
 
-	resp := map[string]any{
-		"user_id": userID,
-		"token":   tokenStr,
+	userID, err := s.storeFor(r).GetUserIDByUsername(username)
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/users", "Failed to look up username", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	if userID == "" {
+		apierr.Write(w, r, apierr.NotFound("user not found"))
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(models.User{ID: userID, Username: username})
 }
 
 // followHandler creates a "follow" relationship between users.
-// Expects JSON body: {"followee_id": 2}
+// Expects JSON body: {"followee_id": "<uuid>"}
 // Uses user_id from JWT token.
+// Returns 400 for a malformed ID or a self-follow, 404 if the followee does
+// not exist and 409 if the caller already follows them.
 func (s *Server) followHandler(w http.ResponseWriter, r *http.Request) {
 	type req struct {
 		FolloweeID string `json:"followee_id"`
@@ -85,30 +190,119 @@ func (s *Server) followHandler(w http.ResponseWriter, r *http.Request) {
 	var body req
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		logg.Error("http/follow", "Invalid request body", err)
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		logg.ErrorContext(r.Context(), "http/follow", "Invalid request body", err)
+		apierr.Write(w, r, apierr.BadRequest("invalid request body"))
+		return
+	}
+	defer r.Body.Close()
+
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		logg.InfoContext(r.Context(), "http/follow", "Unauthorized follow attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
+		return
+	}
+
+	if err := s.validateFollowee(r, userID, body.FolloweeID); err != nil {
+		var apiErr *apierr.Error
+		if errors.As(err, &apiErr) {
+			logg.InfoContext(r.Context(), "http/follow", "Rejected follow", logger.UserRef(userID), "reason", apiErr.Message)
+		} else {
+			logg.ErrorContext(r.Context(), "http/follow", "Failed to look up followee", err)
+		}
+		apierr.Write(w, r, err)
+		return
+	}
+
+	err := s.storeFor(r).CreateFollow(userID, body.FolloweeID)
+	if errors.Is(err, store.ErrAlreadyFollowing) {
+		logg.InfoContext(r.Context(), "http/follow", "User already follows followee", logger.UserRef(userID))
+		apierr.Write(w, r, err)
+		return
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/follow", "Failed to create follow relationship", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	logg.InfoContext(r.Context(), "http/follow", "User followed followee", logger.UserRef(userID))
+	w.WriteHeader(http.StatusOK)
+}
+
+// validateFollowee checks that followeeID names an existing user other than userID.
+func (s *Server) validateFollowee(r *http.Request, userID, followeeID string) error {
+	if err := checkFolloweeID(userID, followeeID); err != nil {
+		return err
+	}
+
+	_, err := s.storeFor(r).GetUser(followeeID)
+	if errors.Is(err, gocql.ErrNotFound) {
+		return apierr.NotFound("followee not found")
+	}
+	return err
+}
+
+// checkFolloweeID rejects a followee_id that is not a UUID or names the caller.
+func checkFolloweeID(userID, followeeID string) error {
+	if _, err := uuid.Parse(followeeID); err != nil {
+		return apierr.BadRequest("followee_id must be a valid UUID").
+			WithDetails(map[string]any{"field": "followee_id"})
+	}
+	if followeeID == userID {
+		return apierr.BadRequest("followee_id must not be your own user ID").
+			WithDetails(map[string]any{"field": "followee_id"})
+	}
+	return nil
+}
+
+// unfollowHandler removes a "follow" relationship between users and queues
+// an event so the worker purges the followee's posts from the caller's feed.
+// Expects JSON body: {"followee_id": "<uuid>"}
+// Uses user_id from JWT token.
+func (s *Server) unfollowHandler(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		FolloweeID string `json:"followee_id"`
+	}
+	var body req
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logg.ErrorContext(r.Context(), "http/unfollow", "Invalid request body", err)
+		apierr.Write(w, r, apierr.BadRequest("invalid request body"))
 		return
 	}
 	defer r.Body.Close()
 
 	userID, ok := middleware.UserIDFromContext(r.Context())
 	if !ok {
-		logg.Info("http/follow", "Unauthorized follow attempt")
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		logg.InfoContext(r.Context(), "http/unfollow", "Unauthorized unfollow attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
+		return
+	}
+	if err := checkFolloweeID(userID, body.FolloweeID); err != nil {
+		logg.InfoContext(r.Context(), "http/unfollow", "Rejected unfollow", logger.UserRef(userID))
+		apierr.Write(w, r, err)
+		return
+	}
+
+	event, err := newOutboxEvent(r.Context(), events.TypeFollowDeleted, userID, models.Follow{UserID: userID, FolloweeID: body.FolloweeID})
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/unfollow", "Failed to encode unfollow event", err)
+		apierr.Write(w, r, err)
 		return
 	}
 
-	if err := s.store.CreateFollow(userID, body.FolloweeID); err != nil {
-		logg.Error("http/follow", "Failed to create follow relationship", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := s.storeFor(r).DeleteFollowWithOutbox(userID, body.FolloweeID, event); err != nil {
+		logg.ErrorContext(r.Context(), "http/unfollow", "Failed to delete follow relationship", err)
+		apierr.Write(w, r, err)
 		return
 	}
 
-	logg.Info("http/follow", "User "+userID+" followed "+body.FolloweeID)
+	logg.InfoContext(r.Context(), "http/unfollow", "User unfollowed followee", logger.UserRef(userID))
 	w.WriteHeader(http.StatusOK)
 }
 
-// createPostHandler handles post creation, stores it in Cassandra, and publishes an event to Kafka.
+// createPostHandler handles post creation, storing the post and its outbox event in Cassandra.
 // Expects JSON body: {"body": "post content"}
 // Returns JSON response with created post data.
 func (s *Server) createPostHandler(w http.ResponseWriter, r *http.Request) {
@@ -118,22 +312,22 @@ func (s *Server) createPostHandler(w http.ResponseWriter, r *http.Request) {
 	var body req
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		logg.Error("http/posts", "Invalid request body", err)
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		logg.ErrorContext(r.Context(), "http/posts", "Invalid request body", err)
+		apierr.Write(w, r, apierr.BadRequest("invalid request body"))
 		return
 	}
 	defer r.Body.Close()
 
 	userID, ok := middleware.UserIDFromContext(r.Context())
 	if !ok {
-		logg.Info("http/posts", "Unauthorized post creation attempt")
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		logg.InfoContext(r.Context(), "http/posts", "Unauthorized post creation attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
 		return
 	}
 
 	if len(body.Body) == 0 || len(body.Body) > 1000 {
-		logg.Info("http/posts", "Post body length invalid for user_id="+userID)
-		http.Error(w, "post body must be 1-1000 characters", http.StatusBadRequest)
+		logg.InfoContext(r.Context(), "http/posts", "Post body length invalid", logger.UserRef(userID))
+		apierr.Write(w, r, apierr.BadRequest("post body must be 1-1000 characters"))
 		return
 	}
 
@@ -144,66 +338,323 @@ func (s *Server) createPostHandler(w http.ResponseWriter, r *http.Request) {
 		Created:  time.Now(),
 	}
 
-	data, err := json.Marshal(post)
+	// The post and its Kafka event are stored together; the relay publishes the event.
+	event, err := newOutboxEvent(r.Context(), events.TypePostCreated, post.ID, post)
 	if err != nil {
-		logg.Error("http/posts", "Failed to marshal post", err)
-		http.Error(w, "failed to marshal post", http.StatusInternalServerError)
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to encode post event", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	if err := s.storeFor(r).AddPostWithOutbox(post, event); err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to save post to Cassandra", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	logg.InfoContext(r.Context(), "http/posts", "Post created successfully", logger.UserRef(userID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// updatePostHandler lets the author edit a post and propagates the change to all feeds.
+// Path parameter: {id} of the post
+// Expects JSON body: {"body": "new content"}
+// Returns JSON response with the updated post.
+func (s *Server) updatePostHandler(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Body string `json:"body"`
+	}
+	var body req
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Invalid request body", err)
+		apierr.Write(w, r, apierr.BadRequest("invalid request body"))
+		return
+	}
+	defer r.Body.Close()
+
+	if len(body.Body) == 0 || len(body.Body) > 1000 {
+		apierr.Write(w, r, apierr.BadRequest("post body must be 1-1000 characters"))
+		return
+	}
+
+	post, ok := s.authorPost(w, r)
+	if !ok {
 		return
 	}
+	post.Body = body.Body
 
-	// Create Kafka message for post creation event.
-	msg := kafka.Message{
-		Key:   []byte("post_created"),
-		Value: data,
+	event, err := newOutboxEvent(r.Context(), events.TypePostUpdated, post.ID, post)
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to encode post event", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	if err := s.storeFor(r).UpdatePostWithOutbox(post, event); err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to update post", err)
+		apierr.Write(w, r, err)
+		return
 	}
 
-	if err := s.kafkaWriter.WriteMessages(msg); err != nil {
-		logg.Error("http/posts", "Failed to write Kafka message", err)
-		http.Error(w, "failed to write Kafka message: "+err.Error(), http.StatusInternalServerError)
+	logg.InfoContext(r.Context(), "http/posts", "Post updated", logger.UserRef(post.AuthorID))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// deletePostHandler lets the author delete a post and removes it from all feeds.
+// Path parameter: {id} of the post
+// Returns 204 No Content.
+func (s *Server) deletePostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := s.authorPost(w, r)
+	if !ok {
 		return
 	}
 
-	if err := s.store.AddPost(post); err != nil {
-		logg.Error("http/posts", "Failed to save post to Cassandra", err)
-		http.Error(w, "failed to save post: "+err.Error(), http.StatusInternalServerError)
+	event, err := newOutboxEvent(r.Context(), events.TypePostDeleted, post.ID, post)
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to encode post event", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	if err := s.storeFor(r).DeletePostWithOutbox(post, event); err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to delete post", err)
+		apierr.Write(w, r, err)
 		return
 	}
 
-	logg.Info("http/posts", "Post created successfully by user_id="+userID)
+	logg.InfoContext(r.Context(), "http/posts", "Post deleted", logger.UserRef(post.AuthorID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorPost loads the post named by the {id} path parameter and checks that
+// the caller is its author. On failure it writes the error response itself.
+func (s *Server) authorPost(w http.ResponseWriter, r *http.Request) (models.Post, bool) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		logg.InfoContext(r.Context(), "http/posts", "Unauthorized post modification attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
+		return models.Post{}, false
+	}
+
+	postID, ok := pathID(w, r)
+	if !ok {
+		return models.Post{}, false
+	}
+	post, err := s.storeFor(r).GetPost(postID)
+	if errors.Is(err, gocql.ErrNotFound) {
+		apierr.Write(w, r, apierr.NotFound("post not found"))
+		return models.Post{}, false
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to load post", err)
+		apierr.Write(w, r, err)
+		return models.Post{}, false
+	}
+
+	if post.AuthorID != userID {
+		logg.InfoContext(r.Context(), "http/posts", "User tried to modify a post of another author", logger.UserRef(userID))
+		apierr.Write(w, r, apierr.Forbidden("only the author can modify this post"))
+		return models.Post{}, false
+	}
+	return post, true
+}
+
+// getPostHandler returns a single post by ID.
+// Path: /posts/{id}
+func (s *Server) getPostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	post, err := s.storeFor(r).GetPost(postID)
+	if errors.Is(err, gocql.ErrNotFound) {
+		apierr.Write(w, r, apierr.NotFound("post not found"))
+		return
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/posts", "Failed to get post", err)
+		apierr.Write(w, r, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(post)
 }
 
+// feedResponse is one page of a user's feed or profile timeline.
+type feedResponse struct {
+	Posts      []models.Post `json:"posts"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
 // getFeedHandler retrieves a user's feed based on their user ID.
-// Query parameters: ?limit=50
+// Query parameters: ?limit=50&cursor=<next_cursor from previous page>
+// Returns JSON response: {"posts": [...], "next_cursor": "..."}
 // Uses user_id from JWT token.
 func (s *Server) getFeedHandler(w http.ResponseWriter, r *http.Request) {
 	limitStr := r.URL.Query().Get("limit")
+	cursor := r.URL.Query().Get("cursor")
 
 	userID, ok := middleware.UserIDFromContext(r.Context())
 	if !ok {
-		logg.Info("http/feed", "Unauthorized feed access attempt")
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		logg.InfoContext(r.Context(), "http/feed", "Unauthorized feed access attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
 		return
 	}
 
-	limit := 50
-	if limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	limit := parseLimit(limitStr)
+
+	feed, next, err := s.storeFor(r).GetFeedPage(userID, limit, cursor)
+	if errors.Is(err, store.ErrInvalidCursor) {
+		logg.InfoContext(r.Context(), "http/feed", "Invalid feed cursor", logger.UserRef(userID))
+		apierr.Write(w, r, apierr.BadRequest("invalid cursor"))
+		return
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/feed", "Failed to get feed", err, logger.UserRef(userID))
+		apierr.Write(w, r, err)
+		return
+	}
+	if feed == nil {
+		feed = []models.Post{}
+	}
+
+	logg.DebugContext(r.Context(), "http/feed", "Feed retrieved", logger.UserRef(userID), "limit", limit)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedResponse{Posts: feed, NextCursor: next})
+}
+
+// getUserPostsHandler returns the posts written by a user, newest first.
+// Path: /users/{id}/posts
+// Query parameters: ?limit=50&cursor=<next_cursor from previous page>
+// Returns JSON response: {"posts": [...], "next_cursor": "..."}
+func (s *Server) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	authorID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	limit := parseLimit(r.URL.Query().Get("limit"))
+
+	posts, next, err := s.storeFor(r).GetAuthorPostsPage(authorID, limit, r.URL.Query().Get("cursor"))
+	if errors.Is(err, store.ErrInvalidCursor) {
+		logg.InfoContext(r.Context(), "http/users", "Invalid posts cursor", logger.UserRef(authorID))
+		apierr.Write(w, r, apierr.BadRequest("invalid cursor"))
+		return
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/users", "Failed to get posts of user", err, logger.UserRef(authorID))
+		apierr.Write(w, r, err)
+		return
+	}
+	if posts == nil {
+		posts = []models.Post{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(feedResponse{Posts: posts, NextCursor: next})
+}
+
+// followListResponse is one page of a follower or following list together
+// with the total size of the list.
+type followListResponse struct {
+	UserIDs    []string `json:"user_ids"`
+	Count      int64    `json:"count"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// getFollowersHandler lists the users following a user.
+// Path: /users/{id}/followers
+// Query parameters: ?limit=50&cursor=<next_cursor from previous page>
+// Returns JSON response: {"user_ids": [...], "count": 123, "next_cursor": "..."}
+func (s *Server) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeFollowList(w, r, s.storeFor(r).GetFollowersPage, func(c models.FollowCounts) int64 { return c.Followers })
+}
+
+// getFollowingHandler lists the users a user follows.
+// Path: /users/{id}/following
+// Query parameters: ?limit=50&cursor=<next_cursor from previous page>
+// Returns JSON response: {"user_ids": [...], "count": 123, "next_cursor": "..."}
+func (s *Server) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	s.writeFollowList(w, r, s.storeFor(r).GetFollowingPage, func(c models.FollowCounts) int64 { return c.Following })
+}
+
+// writeFollowList serves one page of a follow list read by page, with the
+// count picked from the user's follow counts.
+func (s *Server) writeFollowList(
+	w http.ResponseWriter,
+	r *http.Request,
+	page func(userID string, limit int, cursor string) ([]string, string, error),
+	count func(models.FollowCounts) int64,
+) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
 	}
+	limit := parseLimit(r.URL.Query().Get("limit"))
 
-	feed, err := s.store.GetFeed(userID, limit)
+	ids, next, err := page(userID, limit, r.URL.Query().Get("cursor"))
+	if errors.Is(err, store.ErrInvalidCursor) {
+		logg.InfoContext(r.Context(), "http/users", "Invalid follow list cursor", logger.UserRef(userID))
+		apierr.Write(w, r, apierr.BadRequest("invalid cursor"))
+		return
+	}
 	if err != nil {
-		logg.Error("http/feed", "Failed to get feed for user_id="+userID, err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		logg.ErrorContext(r.Context(), "http/users", "Failed to get follow list", err, logger.UserRef(userID))
+		apierr.Write(w, r, err)
 		return
 	}
+	if ids == nil {
+		ids = []string{}
+	}
 
-	logg.Info("http/feed", "Feed retrieved for user_id="+userID+" with limit="+strconv.Itoa(limit))
+	counts, err := s.storeFor(r).GetFollowCounts(userID)
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/users", "Failed to get follow counts", err, logger.UserRef(userID))
+		apierr.Write(w, r, err)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(feed)
+	json.NewEncoder(w).Encode(followListResponse{UserIDs: ids, Count: count(counts), NextCursor: next})
+}
+
+// pathID returns the {id} path parameter if it is a UUID, as all user and
+// post IDs are. Otherwise it writes a 400 response and returns false.
+func pathID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if _, err := uuid.Parse(id); err != nil {
+		logg.InfoContext(r.Context(), "http", "Rejected malformed ID in path")
+		apierr.Write(w, r, apierr.BadRequest("id must be a UUID"))
+		return "", false
+	}
+	return id, true
+}
+
+// parseLimit returns the page size requested via ?limit, defaulting to 50.
+func parseLimit(limitStr string) int {
+	if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+		return l
+	}
+	return 50
+}
+
+// newOutboxEvent wraps payload in a versioned event envelope for the outbox.
+// key is the Kafka message key, which picks the partition: the post ID for
+// post events and the follower ID for follow events. The trace of ctx is stored
+// with the event, so the worker's spans join the request's trace.
+func newOutboxEvent(ctx context.Context, eventType, key string, payload any) (models.OutboxEvent, error) {
+	data, err := events.Encode(eventType, "server", payload)
+	if err != nil {
+		return models.OutboxEvent{}, err
+	}
+	return models.OutboxEvent{Key: key, Payload: data, TraceContext: tracing.Inject(ctx)}, nil
+}
+
+// storeFor returns the store with its queries traced as part of r.
+func (s *Server) storeFor(r *http.Request) store.StoreInterface {
+	return store.WithTrace(s.store, r.Context())
 }
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"time"
+
+	"example.com/cassandrafeed/internal/middleware"
+	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/ttlcache"
+)
+
+// maxRevocationEntries bounds the revocation cache. An entry evicted to make
+// room only costs its ID another lookup in Cassandra.
+const maxRevocationEntries = 100000
+
+// revocationList caches lookups of revoked access tokens and sessions, so
+// JWTAuth does not query Cassandra on every request. Session IDs share the
+// revocation table with token IDs; both are random UUIDs. A revoked ID is
+// remembered until the token expires. An ID found not revoked is trusted for
+// ttl, which bounds how long a token revoked through another server instance
+// keeps working here; revocations through this instance apply immediately.
+type revocationList struct {
+	store store.StoreInterface
+	ttl   time.Duration
+	cache *ttlcache.Cache[string, bool] // token or session ID -> revoked
+}
+
+func newRevocationList(st store.StoreInterface, ttl time.Duration) *revocationList {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &revocationList{
+		store: st,
+		ttl:   ttl,
+		cache: ttlcache.New[string, bool](maxRevocationEntries),
+	}
+}
+
+// IsRevoked reports whether the token or its session has been revoked.
+func (l *revocationList) IsRevoked(tok middleware.Token) (bool, error) {
+	for _, id := range []string{tok.ID, tok.SessionID} {
+		revoked, err := l.isRevoked(id, tok.ExpiresAt)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	return false, nil
+}
+
+func (l *revocationList) isRevoked(id string, expiresAt time.Time) (bool, error) {
+	if revoked, ok := l.cache.Get(id); ok {
+		return revoked, nil
+	}
+
+	revoked, err := l.store.IsTokenRevoked(id)
+	if err != nil {
+		return false, err
+	}
+	until := time.Now().Add(l.ttl)
+	if revoked {
+		until = expiresAt
+	}
+	l.cache.Set(id, revoked, until)
+	return revoked, nil
+}
+
+// RevokeSession rejects all access tokens of the session; until is when the
+// last of them expires.
+func (l *revocationList) RevokeSession(sessionID string, until time.Time) error {
+	if err := l.store.RevokeToken(sessionID, time.Until(until)); err != nil {
+		return err
+	}
+	l.cache.Set(sessionID, true, until)
+	return nil
+}
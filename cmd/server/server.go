@@ -3,49 +3,136 @@ package server
 import (
 	"context"
 	"net/http"
+	"net/netip"
 	"time"
 
-	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/health"
+	config "example.com/cassandrafeed/internal/init"
+	"example.com/cassandrafeed/internal/jwtkeys"
 	"example.com/cassandrafeed/internal/logger"
 	"example.com/cassandrafeed/internal/middleware"
 	"example.com/cassandrafeed/internal/store"
 )
 
 type Server struct {
-	store       store.StoreInterface
-	kafkaWriter appkafka.KafkaWriter
+	store           store.StoreInterface
+	idempotencyTTL  time.Duration
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	keys            *jwtkeys.KeySet
+	revocations     *revocationList
+	limiter         middleware.RateLimiter
+	limits          rateLimits
+	trustedProxies  []netip.Prefix
+	health          *health.Checker
+	drainDelay      time.Duration
+}
+
+// rateLimits are the per-route limits of the API.
+type rateLimits struct {
+	register, login, posts, follow, read config.RateLimit
+}
+
+// newServer creates a Server backed by st that signs tokens with keys.
+func newServer(st store.StoreInterface, keys *jwtkeys.KeySet, cfg *config.Config) *Server {
+	// The server only writes to Cassandra; the relay publishes to Kafka, so
+	// a Kafka outage must not take the API out of rotation.
+	checker := health.New()
+	checker.Add("cassandra", st.Ping)
+
+	return &Server{
+		store:           st,
+		idempotencyTTL:  cfg.IdempotencyKeyTTL,
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		keys:            keys,
+		revocations:     newRevocationList(st, cfg.RevocationCacheTTL),
+		limiter:         middleware.NewMemoryLimiter(),
+		limits: rateLimits{
+			register: cfg.RateLimitRegister,
+			login:    cfg.RateLimitLogin,
+			posts:    cfg.RateLimitPosts,
+			follow:   cfg.RateLimitFollow,
+			read:     cfg.RateLimitRead,
+		},
+		trustedProxies: cfg.TrustedProxies,
+		health:         checker,
+		drainDelay:     cfg.ShutdownDrainDelay,
+	}
 }
 
 var logg = logger.New()
 
-// Run starts the HTTPS server with JWT-protected routes and graceful shutdown.
-func Run(ctx context.Context, st store.StoreInterface, writer appkafka.KafkaWriter, addr string) {
-	s := &Server{
-		store:       st,
-		kafkaWriter: writer,
+// routes builds the versioned API router. Every route names its method, so
+// the mux answers other methods on a known path with 405 and an Allow header.
+// Unknown routes get the same JSON error body as every other error. Every
+// request is tagged with an X-Request-ID that error responses echo.
+func (s *Server) routes() http.Handler {
+	auth := middleware.JWTAuth(s.keys, s.revocations)
+	idempotent := middleware.Idempotency(s.store, s.idempotencyTTL)
+
+	// Each group of routes shares one rate limit bucket per caller.
+	limit := func(group string, l config.RateLimit) func(http.Handler) http.Handler {
+		return middleware.RateLimit(s.limiter, group, l)
 	}
+	register := limit("register", s.limits.register)
+	login := limit("login", s.limits.login)
+	posts := limit("posts", s.limits.posts)
+	follow := limit("follow", s.limits.follow)
+	read := limit("read", s.limits.read)
 
-	// --- HTTP routes ---
 	mux := http.NewServeMux()
 
-	// Protected endpoints with JWT authentication middleware
-	mux.Handle("/posts", middleware.JWTAuth(http.HandlerFunc(s.createPostHandler)))
-	mux.Handle("/follow", middleware.JWTAuth(http.HandlerFunc(s.followHandler)))
-	mux.Handle("/feed", middleware.JWTAuth(http.HandlerFunc(s.getFeedHandler)))
+	// Public keys for verifying our tokens
+	mux.Handle("GET /.well-known/jwks.json", http.HandlerFunc(s.jwksHandler))
+
+	// Liveness and readiness probes (GET /healthz, GET /readyz)
+	s.health.Register(mux)
 
-	// Public endpoint for user registration (no JWT required)
-	mux.Handle("/users", http.HandlerFunc(s.createUserHandler))
+	// Public endpoints for registration and login (no JWT required), limited per client IP
+	mux.Handle("POST /v1/users", register(http.HandlerFunc(s.createUserHandler)))
+	mux.Handle("POST /v1/sessions", login(http.HandlerFunc(s.loginHandler)))
+	mux.Handle("POST /v1/token/refresh", login(http.HandlerFunc(s.refreshHandler)))
+
+	// Protected endpoints with JWT authentication middleware, limited per user
+	mux.Handle("POST /v1/logout", auth(login(http.HandlerFunc(s.logoutHandler))))
+	mux.Handle("POST /v1/logout/all", auth(login(http.HandlerFunc(s.logoutAllHandler))))
+	mux.Handle("POST /v1/posts", auth(posts(idempotent(http.HandlerFunc(s.createPostHandler)))))
+	mux.Handle("GET /v1/posts/{id}", auth(read(http.HandlerFunc(s.getPostHandler))))
+	mux.Handle("PATCH /v1/posts/{id}", auth(posts(http.HandlerFunc(s.updatePostHandler))))
+	mux.Handle("DELETE /v1/posts/{id}", auth(posts(http.HandlerFunc(s.deletePostHandler))))
+	mux.Handle("POST /v1/follow", auth(follow(http.HandlerFunc(s.followHandler))))
+	mux.Handle("POST /v1/unfollow", auth(follow(http.HandlerFunc(s.unfollowHandler))))
+	mux.Handle("GET /v1/feed", auth(read(http.HandlerFunc(s.getFeedHandler))))
+	mux.Handle("GET /v1/users", auth(read(http.HandlerFunc(s.lookupUserHandler))))
+	mux.Handle("GET /v1/users/{id}", auth(read(http.HandlerFunc(s.getUserHandler))))
+	mux.Handle("GET /v1/users/{id}/posts", auth(read(http.HandlerFunc(s.getUserPostsHandler))))
+	mux.Handle("GET /v1/users/{id}/followers", auth(read(http.HandlerFunc(s.getFollowersHandler))))
+	mux.Handle("GET /v1/users/{id}/following", auth(read(http.HandlerFunc(s.getFollowingHandler))))
+
+	return middleware.RequestID(middleware.ClientIP(s.trustedProxies)(middleware.Tracing(middleware.Metrics(middleware.RouteErrors(mux)))))
+}
+
+// Run starts the HTTPS server with JWT-protected routes and graceful shutdown.
+func Run(ctx context.Context, st store.StoreInterface, keys *jwtkeys.KeySet, cfg *config.Config) {
+	s := newServer(st, keys, cfg)
+	s.health.ShutdownOn(ctx)
+	go keys.Watch(ctx, cfg.JWTKeysReloadInterval)
+
+	// Serve /metrics on its own listener, not to API clients
+	go health.Serve(cfg.MetricsAddr, s.health)
+	addr := cfg.ServerAddr
 
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      s.routes(),
 		ReadTimeout:  10 * time.Second, // prevent slowloris attacks
 		WriteTimeout: 10 * time.Second,
 	}
 
 	// --- Start server in a goroutine ---
 	go func() {
-		logg.Info("server", "Starting HTTPS server on "+addr)
+		logg.Info("server", "Starting HTTPS server", "addr", addr)
 		// TLS: cert.pem and key.pem should be valid certificates in specified paths
 		if err := srv.ListenAndServeTLS("/certs/cert.pem", "/certs/key.pem"); err != nil && err != http.ErrServerClosed {
 			logg.Error("server", "Server stopped unexpectedly", err)
@@ -56,6 +143,12 @@ func Run(ctx context.Context, st store.StoreInterface, writer appkafka.KafkaWrit
 	<-ctx.Done()
 	logg.Info("server", "Shutdown signal received")
 
+	// /readyz now fails; keep serving until load balancers have noticed
+	if s.drainDelay > 0 {
+		logg.Info("server", "Draining before shutdown", "delay", s.drainDelay.String())
+		time.Sleep(s.drainDelay)
+	}
+
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := srv.Shutdown(shutdownCtx); err != nil {
@@ -20,8 +20,7 @@ func TestServer_GracefulShutdown(t *testing.T) {
 	mockKafka := &appkafka.MockKafka{}
 
 	s := &Server{
-		store:       mockStore,
-		kafkaWriter: mockKafka,
+		store: mockStore,
 	}
 
 	// Register HTTP handlers for testing
@@ -2,20 +2,37 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"example.com/cassandrafeed/cmd/relay"
 	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/events"
+	config "example.com/cassandrafeed/internal/init"
+	"example.com/cassandrafeed/internal/jwtkeys"
+	"example.com/cassandrafeed/internal/metrics"
 	"example.com/cassandrafeed/internal/middleware"
 	"example.com/cassandrafeed/internal/models"
 	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/segmentio/kafka-go"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 //
@@ -26,6 +43,8 @@ import (
 func makeTestJWT(userID string) string {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userID,
+		"sid":     uuid.NewString(),
+		"jti":     uuid.NewString(),
 		"exp":     time.Now().Add(time.Hour).Unix(),
 	})
 	tokenStr, err := token.SignedString([]byte("test-secret"))
@@ -64,31 +83,34 @@ func sendJSONRequest(t *testing.T, method, url string, body any, token string, e
 	return resp
 }
 
+// configuration of test servers
+var testConfig = &config.Config{
+	IdempotencyKeyTTL:  time.Hour,
+	AccessTokenTTL:     time.Hour,
+	RefreshTokenTTL:    time.Hour,
+	RevocationCacheTTL: time.Minute,
+}
+
+// password used for users registered over HTTP
+const testPassword = "correct horse battery"
+
 //
 // --- Setup test server ---
 //
 
 func setupTestServer(t *testing.T) (*Server, *httptest.Server) {
 	t.Helper()
+	passwordCost = bcrypt.MinCost // keep registration fast in tests
+
 	mockStore := store.NewMock()
-	s := &Server{
-		store:       mockStore,
-		kafkaWriter: &appkafka.MockKafka{Store: mockStore},
-	}
+	s := newServer(mockStore, testKeys(t), testConfig)
 
-	mux := http.NewServeMux()
-	mux.HandleFunc("/users", s.createUserHandler)
-	mux.HandleFunc("/follow", func(w http.ResponseWriter, r *http.Request) {
-		middleware.JWTAuth(http.HandlerFunc(s.followHandler)).ServeHTTP(w, r)
-	})
-	mux.HandleFunc("/posts", func(w http.ResponseWriter, r *http.Request) {
-		middleware.JWTAuth(http.HandlerFunc(s.createPostHandler)).ServeHTTP(w, r)
-	})
-	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
-		middleware.JWTAuth(http.HandlerFunc(s.getFeedHandler)).ServeHTTP(w, r)
-	})
+	// Relay outbox events to the mock Kafka, which applies them to feeds immediately
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go relay.New(mockStore, &appkafka.MockKafka{Store: mockStore}, 10*time.Millisecond, 0).Run(ctx)
 
-	return s, httptest.NewServer(mux)
+	return s, httptest.NewServer(s.routes())
 }
 
 //
@@ -106,6 +128,266 @@ func TestCreateUser(t *testing.T) {
 	}
 }
 
+// registration refuses taken usernames and login checks the password
+func TestRegisterAndLogin(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	register := func(username, password string, status int) {
+		t.Helper()
+		resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{username, password}, "", status)
+		resp.Body.Close()
+	}
+	register("almaz", testPassword, http.StatusCreated)
+	register("almaz", "another password", http.StatusConflict)
+	register("nur", "short", http.StatusBadRequest)
+
+	// Accounts registered before passwords existed cannot log in.
+	s.store.CreateUser("legacy", "")
+
+	for _, tc := range []struct {
+		name               string
+		username, password string
+		status             int
+	}{
+		{"valid", "almaz", testPassword, http.StatusOK},
+		{"wrong password", "almaz", "wrong password", http.StatusUnauthorized},
+		{"unknown user", "nobody", testPassword, http.StatusUnauthorized},
+		{"no password set", "legacy", "", http.StatusUnauthorized},
+	} {
+		resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/sessions", credentialsRequest{tc.username, tc.password}, "", tc.status)
+		var session sessionResponse
+		json.NewDecoder(resp.Body).Decode(&session)
+		resp.Body.Close()
+
+		if tc.status != http.StatusOK {
+			continue
+		}
+		almazID, _ := s.store.GetUserIDByUsername("almaz")
+		if session.UserID != almazID || session.Token == "" {
+			t.Fatalf("%s: unexpected session %+v", tc.name, session)
+		}
+		sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, session.Token, http.StatusOK).Body.Close()
+	}
+}
+
+// decodeSession reads the session of a registration, login or refresh.
+func decodeSession(t *testing.T, resp *http.Response) sessionResponse {
+	t.Helper()
+	defer resp.Body.Close()
+	var sess sessionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sess); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if sess.Token == "" || sess.RefreshToken == "" || sess.ExpiresIn != int(time.Hour.Seconds()) {
+		t.Fatalf("incomplete session: %+v", sess)
+	}
+	return sess
+}
+
+// refresh tokens work once and logout kills all tokens of the session
+func TestRefreshAndLogout(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	first := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{"almaz", testPassword}, "", http.StatusCreated))
+	other := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/sessions", credentialsRequest{"almaz", testPassword}, "", http.StatusOK))
+
+	// Refreshing rotates the refresh token within the session.
+	second := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{first.RefreshToken}, "", http.StatusOK))
+	if second.UserID != first.UserID || second.RefreshToken == first.RefreshToken {
+		t.Fatalf("expected new tokens of the same user, got %+v", second)
+	}
+
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, second.Token, http.StatusOK).Body.Close()
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/logout", nil, second.Token, http.StatusNoContent).Body.Close()
+
+	// All tokens of the logged-out session are dead; other sessions are not.
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, second.Token, http.StatusUnauthorized).Body.Close()
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, first.Token, http.StatusUnauthorized).Body.Close()
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{second.RefreshToken}, "", http.StatusUnauthorized).Body.Close()
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, other.Token, http.StatusOK).Body.Close()
+	decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{other.RefreshToken}, "", http.StatusOK))
+}
+
+// reusing an exchanged refresh token revokes the session it belongs to
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	first := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{"almaz", testPassword}, "", http.StatusCreated))
+	second := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{first.RefreshToken}, "", http.StatusOK))
+
+	// A stolen copy of the first refresh token is presented again.
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{first.RefreshToken}, "", http.StatusUnauthorized).Body.Close()
+
+	// The whole session is gone, including the legitimate client's tokens.
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{second.RefreshToken}, "", http.StatusUnauthorized).Body.Close()
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, second.Token, http.StatusUnauthorized).Body.Close()
+}
+
+// logging out of all sessions kills the tokens of every session of the user
+// a full revocation cache still records revocations
+func TestRevocationList_Full(t *testing.T) {
+	l := newRevocationList(store.NewMock(), time.Hour)
+	tok := middleware.Token{ID: uuid.NewString(), SessionID: uuid.NewString(), ExpiresAt: time.Now().Add(time.Hour)}
+	if revoked, err := l.IsRevoked(tok); err != nil || revoked {
+		t.Fatalf("expected a fresh token not to be revoked, got %v, %v", revoked, err)
+	}
+	for l.cache.Len() < maxRevocationEntries {
+		l.cache.Set(uuid.NewString(), false, time.Now().Add(time.Hour))
+	}
+
+	if err := l.RevokeSession(tok.SessionID, tok.ExpiresAt); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if revoked, _ := l.IsRevoked(tok); !revoked {
+		t.Fatal("expected the revocation to apply although the cache is full")
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	first := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{"almaz", testPassword}, "", http.StatusCreated))
+	second := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/sessions", credentialsRequest{"almaz", testPassword}, "", http.StatusOK))
+	stranger := decodeSession(t, sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{"nur", testPassword}, "", http.StatusCreated))
+
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/logout/all", nil, first.Token, http.StatusNoContent).Body.Close()
+
+	for _, sess := range []sessionResponse{first, second} {
+		sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, sess.Token, http.StatusUnauthorized).Body.Close()
+		sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/token/refresh", refreshRequest{sess.RefreshToken}, "", http.StatusUnauthorized).Body.Close()
+	}
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, stranger.Token, http.StatusOK).Body.Close()
+}
+
+// access tokens without a jti or sid cannot be revoked and are rejected
+func TestJWTAuth_RequiresTokenID(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	for _, claims := range []jwt.MapClaims{
+		{"user_id": "1", "sid": uuid.NewString()},
+		{"user_id": "1", "jti": uuid.NewString()},
+	} {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+		sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, token, http.StatusUnauthorized).Body.Close()
+	}
+}
+
+// tokens signed with asymmetric keys verify on every server holding the key,
+// so the signing key can be rotated one server at a time
+func TestAsymmetricKeys_Rotation(t *testing.T) {
+	passwordCost = bcrypt.MinCost
+	dir := t.TempDir()
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	writeKeyFile(t, dir, "2026-01", edKey)
+	writeKeyFile(t, dir, "2026-02", rsaKey)
+
+	oldKeys, err := jwtkeys.LoadDir(dir, "2026-01")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	newKeys, err := jwtkeys.LoadDir(dir, "2026-02")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	st := store.NewMock()
+	oldTS := httptest.NewServer(newServer(st, oldKeys, testConfig).routes())
+	defer oldTS.Close()
+	newTS := httptest.NewServer(newServer(st, newKeys, testConfig).routes())
+	defer newTS.Close()
+
+	register := func(ts *httptest.Server, username string) string {
+		t.Helper()
+		resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{username, testPassword}, "", http.StatusCreated)
+		defer resp.Body.Close()
+		var sess sessionResponse
+		json.NewDecoder(resp.Body).Decode(&sess)
+		return sess.Token
+	}
+	oldToken := register(oldTS, "almaz")
+	newToken := register(newTS, "nur")
+
+	for token, alg := range map[string]string{oldToken: "EdDSA", newToken: "RS256"} {
+		parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		if parsed.Method.Alg() != alg {
+			t.Fatalf("expected %s token, got %s", alg, parsed.Method.Alg())
+		}
+		for _, ts := range []*httptest.Server{oldTS, newTS} {
+			sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, token, http.StatusOK).Body.Close()
+		}
+	}
+
+	// HMAC tokens are not accepted once asymmetric keys are configured.
+	sendJSONRequest(t, http.MethodGet, newTS.URL+"/v1/feed", nil, makeTestJWT("1"), http.StatusUnauthorized).Body.Close()
+
+	resp := sendJSONRequest(t, http.MethodGet, newTS.URL+"/.well-known/jwks.json", nil, "", http.StatusOK)
+	var jwks jwtkeys.JWKS
+	json.NewDecoder(resp.Body).Decode(&jwks)
+	resp.Body.Close()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "2026-01" || jwks.Keys[0].KeyType != "OKP" ||
+		jwks.Keys[1].KeyID != "2026-02" || jwks.Keys[1].KeyType != "RSA" || jwks.Keys[1].N == "" {
+		t.Fatalf("unexpected JWKS: %+v", jwks)
+	}
+
+	// Retiring the old key invalidates the tokens it signed.
+	os.Remove(filepath.Join(dir, "2026-01.pem"))
+	if err := newKeys.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	sendJSONRequest(t, http.MethodGet, newTS.URL+"/v1/feed", nil, oldToken, http.StatusUnauthorized).Body.Close()
+	sendJSONRequest(t, http.MethodGet, newTS.URL+"/v1/feed", nil, newToken, http.StatusOK).Body.Close()
+}
+
+// registration is limited per client IP and posting per user
+func TestRateLimit(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	passwordCost = bcrypt.MinCost
+
+	cfg := *testConfig
+	cfg.RateLimitRegister = config.RateLimit{Requests: 2, Per: time.Minute}
+	cfg.RateLimitPosts = config.RateLimit{Requests: 1, Per: time.Hour}
+	st := store.NewMock()
+	ts := httptest.NewServer(newServer(st, testKeys(t), &cfg).routes())
+	defer ts.Close()
+
+	for i, status := range []int{http.StatusCreated, http.StatusCreated, http.StatusTooManyRequests} {
+		resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/users", credentialsRequest{"user" + strconv.Itoa(i), testPassword}, "", status)
+		resp.Body.Close()
+		if got := resp.Header.Get("RateLimit-Remaining"); got != strconv.Itoa(max(1-i, 0)) {
+			t.Fatalf("registration %d: expected RateLimit-Remaining %d, got %q", i, max(1-i, 0), got)
+		}
+		if status == http.StatusTooManyRequests && resp.Header.Get("Retry-After") != "30" {
+			t.Fatalf("expected Retry-After 30, got %q", resp.Header.Get("Retry-After"))
+		}
+	}
+	if len(st.Users) != 2 {
+		t.Fatalf("expected 2 users, got %d", len(st.Users))
+	}
+
+	post := map[string]any{"body": "hello"}
+	alice, bob := makeTestJWT(uuid.NewString()), makeTestJWT(uuid.NewString())
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/posts", post, alice, http.StatusOK).Body.Close()
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/posts", post, alice, http.StatusTooManyRequests).Body.Close()
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/posts", post, bob, http.StatusOK).Body.Close()
+
+	// Routes without a configured limit are not limited.
+	for i := 0; i < 5; i++ {
+		sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/feed", nil, alice, http.StatusOK).Body.Close()
+	}
+}
+
 // full flow: follow -> post -> feed
 func TestFollowAndFeedFlow(t *testing.T) {
 	os.Setenv("JWT_SECRET", "test-secret")
@@ -113,20 +395,20 @@ func TestFollowAndFeedFlow(t *testing.T) {
 	s, ts := setupTestServer(t)
 	defer ts.Close()
 
-	almazID, _ := s.store.CreateUser("almaz")
-	nurID, _ := s.store.CreateUser("nur")
+	almazID, _ := s.store.CreateUser("almaz", "")
+	nurID, _ := s.store.CreateUser("nur", "")
 
 	almazToken := makeTestJWT(almazID)
 	nurToken := makeTestJWT(nurID)
 
 	// Almaz -> follow Nur
 	followReq := map[string]any{"followee_id": nurID}
-	sendJSONRequest(t, http.MethodPost, ts.URL+"/follow", followReq, almazToken, http.StatusOK)
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/follow", followReq, almazToken, http.StatusOK)
 
 	// Nur -> create post
 	postBody := "Hello from Nur!"
 	postReq := map[string]any{"body": postBody}
-	sendJSONRequest(t, http.MethodPost, ts.URL+"/posts", postReq, nurToken, http.StatusOK)
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/posts", postReq, nurToken, http.StatusOK)
 
 	// Almaz -> check feed (polling)
 	deadline := time.Now().Add(1 * time.Second)
@@ -143,13 +425,527 @@ func TestFollowAndFeedFlow(t *testing.T) {
 	t.Fatalf("expected post in feed")
 }
 
+// unfollow removes the relationship and purges the author's posts from the feed
+func TestUnfollowPurgesFeed(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	almazID, _ := s.store.CreateUser("almaz", "")
+	nurID, _ := s.store.CreateUser("nur", "")
+	almazToken := makeTestJWT(almazID)
+	nurToken := makeTestJWT(nurID)
+
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/follow", map[string]any{"followee_id": nurID}, almazToken, http.StatusOK)
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/posts", map[string]any{"body": "soon gone"}, nurToken, http.StatusOK)
+
+	waitForFeed(t, ts, almazToken, func(feed []models.Post) bool { return len(feed) == 1 })
+
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/unfollow", map[string]any{"followee_id": nurID}, almazToken, http.StatusOK)
+
+	if followers, _ := s.store.GetFollowers(nurID); len(followers) != 0 {
+		t.Fatalf("expected no followers after unfollow, got %v", followers)
+	}
+	waitForFeed(t, ts, almazToken, func(feed []models.Post) bool { return len(feed) == 0 })
+}
+
+// unfollow rejects a missing or malformed followee_id and the caller's own ID
+func TestUnfollowValidatesFollowee(t *testing.T) {
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	userID := uuid.NewString()
+	token := makeTestJWT(userID)
+	for _, followee := range []string{"", "not-a-uuid", userID} {
+		resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/unfollow", map[string]any{"followee_id": followee}, token, http.StatusBadRequest)
+		resp.Body.Close()
+	}
+}
+
+// edit and delete a post: only the author may, and feeds follow along
+func TestEditAndDeletePost(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	almazID, _ := s.store.CreateUser("almaz", "")
+	nurID, _ := s.store.CreateUser("nur", "")
+	almazToken := makeTestJWT(almazID)
+	nurToken := makeTestJWT(nurID)
+
+	sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/follow", map[string]any{"followee_id": nurID}, almazToken, http.StatusOK)
+	resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/posts", map[string]any{"body": "first draft"}, nurToken, http.StatusOK)
+	var post models.Post
+	json.NewDecoder(resp.Body).Decode(&post)
+	resp.Body.Close()
+
+	waitForFeed(t, ts, almazToken, func(feed []models.Post) bool { return len(feed) == 1 })
+
+	// Only the author may edit
+	sendJSONRequest(t, http.MethodPatch, ts.URL+"/v1/posts/"+post.ID, map[string]any{"body": "hijacked"}, almazToken, http.StatusForbidden)
+	sendJSONRequest(t, http.MethodPatch, ts.URL+"/v1/posts/"+post.ID, map[string]any{"body": "final"}, nurToken, http.StatusOK)
+	waitForFeed(t, ts, almazToken, func(feed []models.Post) bool { return len(feed) == 1 && feed[0].Body == "final" })
+
+	// Only the author may delete, and the post leaves the follower's feed
+	sendJSONRequest(t, http.MethodDelete, ts.URL+"/v1/posts/"+post.ID, nil, almazToken, http.StatusForbidden)
+	sendJSONRequest(t, http.MethodDelete, ts.URL+"/v1/posts/"+post.ID, nil, nurToken, http.StatusNoContent)
+	waitForFeed(t, ts, almazToken, func(feed []models.Post) bool { return len(feed) == 0 })
+
+	sendJSONRequest(t, http.MethodDelete, ts.URL+"/v1/posts/"+post.ID, nil, nurToken, http.StatusNotFound)
+}
+
+// feed pagination: walk all pages via next_cursor
+func TestFeedPagination(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	mockStore := s.store.(*store.MockStore)
+	userID, _ := mockStore.CreateUser("reader", "")
+	token := makeTestJWT(userID)
+
+	for i := 0; i < 5; i++ {
+		mockStore.AddToFeed(userID, models.Post{ID: strconv.Itoa(i), Body: "post " + strconv.Itoa(i)})
+	}
+
+	var seen []string
+	query := "?limit=2"
+	for pages := 0; pages < 10; pages++ {
+		page := getFeedPageHelper(t, ts, token, query)
+		for _, p := range page.Posts {
+			seen = append(seen, p.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query = "?limit=2&cursor=" + page.NextCursor
+	}
+
+	if len(seen) != 5 {
+		t.Fatalf("expected 5 posts across pages, got %v", seen)
+	}
+}
+
+// profile timeline lists only the author's posts, newest first, across pages
+func TestUserPostsTimeline(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	mockStore := s.store.(*store.MockStore)
+	authorID, _ := mockStore.CreateUser("author", "")
+	token := makeTestJWT(authorID)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		mockStore.AddPost(models.Post{ID: strconv.Itoa(i), AuthorID: authorID, Created: start.Add(time.Duration(i) * time.Second)})
+	}
+	mockStore.AddPost(models.Post{ID: "other", AuthorID: "someone-else", Created: start})
+
+	var seen []string
+	query := "?limit=2"
+	for pages := 0; pages < 10; pages++ {
+		var page feedResponse
+		resp := sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users/"+authorID+"/posts"+query, nil, token, http.StatusOK)
+		json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		for _, p := range page.Posts {
+			seen = append(seen, p.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query = "?limit=2&cursor=" + page.NextCursor
+	}
+
+	if len(seen) != 3 || seen[0] != "2" || seen[2] != "0" {
+		t.Fatalf("expected the author's 3 posts newest first, got %v", seen)
+	}
+}
+
+// follower and following lists page through IDs and report the total count
+func TestFollowLists(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	starID, _ := s.store.CreateUser("star", "")
+	token := makeTestJWT(starID)
+	for i := 0; i < 3; i++ {
+		fanID, _ := s.store.CreateUser("fan"+strconv.Itoa(i), "")
+		sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/follow", map[string]any{"followee_id": starID}, makeTestJWT(fanID), http.StatusOK)
+	}
+
+	var followers []string
+	query := "?limit=2"
+	for pages := 0; pages < 10; pages++ {
+		var page followListResponse
+		resp := sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users/"+starID+"/followers"+query, nil, token, http.StatusOK)
+		json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if page.Count != 3 {
+			t.Fatalf("expected follower count 3, got %d", page.Count)
+		}
+		followers = append(followers, page.UserIDs...)
+		if page.NextCursor == "" {
+			break
+		}
+		query = "?limit=2&cursor=" + page.NextCursor
+	}
+	if len(followers) != 3 {
+		t.Fatalf("expected 3 followers across pages, got %v", followers)
+	}
+
+	var following followListResponse
+	resp := sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users/"+followers[0]+"/following", nil, token, http.StatusOK)
+	json.NewDecoder(resp.Body).Decode(&following)
+	resp.Body.Close()
+	if following.Count != 1 || len(following.UserIDs) != 1 || following.UserIDs[0] != starID {
+		t.Fatalf("expected the fan to follow only the star, got %+v", following)
+	}
+}
+
+// retrying POST /posts with the same Idempotency-Key replays the first response
+func TestCreatePost_IdempotencyKey(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	authorID, _ := s.store.CreateUser("author", "")
+	token := makeTestJWT(authorID)
+
+	createPost := func(body string) (*http.Response, models.Post) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/posts", bytes.NewReader([]byte(`{"body":"`+body+`"}`)))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(middleware.IdempotencyHeader, "retry-1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		var post models.Post
+		json.NewDecoder(resp.Body).Decode(&post)
+		return resp, post
+	}
+
+	resp, first := createPost("only once")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	resp, retry := createPost("only once")
+	if resp.StatusCode != http.StatusOK || retry.ID != first.ID || resp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected replay of post %s, got %d %+v", first.ID, resp.StatusCode, retry)
+	}
+	if posts := s.store.(*store.MockStore).Posts; len(posts) != 1 {
+		t.Fatalf("expected a single stored post, got %d", len(posts))
+	}
+
+	if resp, _ := createPost("something else"); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a reused key with a different body, got %d", resp.StatusCode)
+	}
+}
+
+// single post and user lookups, including resolving a username
+func TestLookupEndpoints(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	nurID, _ := s.store.CreateUser("nur", "")
+	token := makeTestJWT(nurID)
+	postID := uuid.NewString()
+	s.store.AddPost(models.Post{ID: postID, AuthorID: nurID, Body: "hello"})
+
+	var post models.Post
+	resp := sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/posts/"+postID, nil, token, http.StatusOK)
+	json.NewDecoder(resp.Body).Decode(&post)
+	resp.Body.Close()
+	if post.Body != "hello" {
+		t.Fatalf("unexpected post: %+v", post)
+	}
+
+	for _, path := range []string{"/v1/users/" + nurID, "/v1/users?username=nur"} {
+		var user models.User
+		resp := sendJSONRequest(t, http.MethodGet, ts.URL+path, nil, token, http.StatusOK)
+		json.NewDecoder(resp.Body).Decode(&user)
+		resp.Body.Close()
+		if user.ID != nurID || user.Username != "nur" {
+			t.Fatalf("%s: unexpected user %+v", path, user)
+		}
+	}
+
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/posts/"+uuid.NewString(), nil, token, http.StatusNotFound)
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users/"+uuid.NewString(), nil, token, http.StatusNotFound)
+
+	// IDs that are not UUIDs are rejected before they reach the store
+	for _, path := range []string{
+		"/v1/posts/missing",
+		"/v1/users/missing",
+		"/v1/users/missing/posts",
+		"/v1/users/missing/followers",
+		"/v1/users/missing/following",
+	} {
+		sendJSONRequest(t, http.MethodGet, ts.URL+path, nil, token, http.StatusBadRequest).Body.Close()
+	}
+	for _, method := range []string{http.MethodPatch, http.MethodDelete} {
+		sendJSONRequest(t, method, ts.URL+"/v1/posts/missing", map[string]string{"body": "x"}, token, http.StatusBadRequest).Body.Close()
+	}
+
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users?username=nobody", nil, token, http.StatusNotFound)
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users", nil, token, http.StatusBadRequest)
+}
+
+// routes only accept their declared methods and advertise them via Allow
+func TestRoutes_MethodNotAllowed(t *testing.T) {
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	for path, allow := range map[string]string{
+		"/v1/posts": "POST",
+		"/v1/feed":  "GET, HEAD",
+		"/v1/users": "GET, HEAD, POST",
+	} {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		var body struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != allow {
+			t.Fatalf("%s: expected 405 with Allow %q, got %d %q", path, allow, resp.StatusCode, resp.Header.Get("Allow"))
+		}
+		if body.Code != "method_not_allowed" {
+			t.Fatalf("%s: expected code method_not_allowed, got %q", path, body.Code)
+		}
+	}
+}
+
+// unknown paths get the JSON error body instead of the mux's plain text
+func TestRoutes_NotFound(t *testing.T) {
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/v1/nope")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Code      string `json:"code"`
+		RequestID string `json:"request_id"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if resp.StatusCode != http.StatusNotFound || body.Code != "not_found" || body.RequestID == "" {
+		t.Fatalf("expected JSON 404 with a request ID, got %d %+v", resp.StatusCode, body)
+	}
+}
+
+// a post's outbox event carries the trace of the request that created it
+func TestCreatePost_StoresTraceContext(t *testing.T) {
+	tracing.Init(context.Background(), "", 1, "test")
+
+	st := store.NewMock()
+	ts := httptest.NewServer(newServer(st, testKeys(t), testConfig).routes())
+	defer ts.Close()
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/posts", strings.NewReader(`{"body":"traced"}`))
+	req.Header.Set("Authorization", "Bearer "+makeTestJWT(uuid.NewString()))
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	if len(st.Outbox) != 1 {
+		t.Fatalf("expected 1 outbox event, got %d", len(st.Outbox))
+	}
+	if got := st.Outbox[0].TraceContext["traceparent"]; !strings.Contains(got, traceID) {
+		t.Fatalf("expected traceparent of trace %s, got %q", traceID, got)
+	}
+	if post := decodeOutboxPost(t, st.Outbox[0]); st.Outbox[0].Key != post.ID {
+		t.Fatalf("expected the event to be keyed by post ID %s, got %q", post.ID, st.Outbox[0].Key)
+	}
+}
+
+func decodeOutboxPost(t *testing.T, event models.OutboxEvent) models.Post {
+	t.Helper()
+	var env events.Envelope
+	var post models.Post
+	if err := json.Unmarshal(event.Payload, &env); err != nil {
+		t.Fatalf("invalid outbox envelope: %v", err)
+	}
+	if err := json.Unmarshal(env.Payload, &post); err != nil {
+		t.Fatalf("invalid outbox post: %v", err)
+	}
+	return post
+}
+
+// requests are counted per route pattern and method, and /metrics is not
+// served to API clients
+func TestMetrics(t *testing.T) {
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	token := makeTestJWT(uuid.NewString())
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/v1/users/"+uuid.NewString(), nil, token, http.StatusNotFound).Body.Close()
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/no/such/route", nil, "", http.StatusNotFound).Body.Close()
+	sendJSONRequest(t, "BREW", ts.URL+"/v1/feed", nil, "", http.StatusMethodNotAllowed).Body.Close()
+	sendJSONRequest(t, http.MethodGet, ts.URL+"/metrics", nil, "", http.StatusNotFound).Body.Close()
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.Bytes()
+
+	for _, want := range []string{
+		`feed_http_requests_total{method="GET",route="/v1/users/{id}",status="404"}`,
+		`feed_http_requests_total{method="GET",route="unmatched",status="404"}`,
+		`feed_http_requests_total{method="OTHER",route="unmatched",status="405"}`,
+		`feed_http_request_duration_seconds_bucket{method="GET",route="/v1/users/{id}"`,
+	} {
+		if !bytes.Contains(body, []byte(want)) {
+			t.Fatalf("expected metrics to contain %s", want)
+		}
+	}
+}
+
+// readiness follows the store and fails once shutdown has begun
+func TestHealthProbes(t *testing.T) {
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	probe := func(path string, status int, want string) {
+		t.Helper()
+		resp := sendJSONRequest(t, http.MethodGet, ts.URL+path, nil, "", status)
+		defer resp.Body.Close()
+		var rep struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(resp.Body).Decode(&rep)
+		if rep.Status != want {
+			t.Fatalf("%s: expected status %q, got %q", path, want, rep.Status)
+		}
+	}
+
+	probe("/healthz", http.StatusOK, "ok")
+	probe("/readyz", http.StatusOK, "ok")
+
+	s.health.Add("cassandra", (&store.MockStoreFail{}).Ping)
+	probe("/readyz", http.StatusServiceUnavailable, "unavailable")
+	probe("/healthz", http.StatusOK, "ok")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.health.ShutdownOn(ctx)
+	cancel()
+	time.Sleep(10 * time.Millisecond) // let the checker notice
+	probe("/readyz", http.StatusServiceUnavailable, "shutting_down")
+}
+
+// errors come back as a JSON envelope tagged with the request ID
+func TestErrorResponses(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	token := makeTestJWT("1")
+	cases := []struct {
+		name   string
+		setup  func()
+		method string
+		path   string
+		body   string
+		token  string
+		status int
+		code   string
+		msg    string
+	}{
+		{name: "not found", method: http.MethodGet, path: "/v1/posts/" + uuid.NewString(), token: token,
+			status: http.StatusNotFound, code: "not_found", msg: "post not found"},
+		{name: "missing token", method: http.MethodGet, path: "/v1/feed",
+			status: http.StatusUnauthorized, code: "unauthorized", msg: "missing Authorization header"},
+		{name: "bad body", method: http.MethodPost, path: "/v1/users", body: `{"username":""}`,
+			status: http.StatusBadRequest, code: "invalid_request", msg: "username must be 1-50 characters"},
+		{name: "store failure is not leaked", setup: func() { s.store = &store.MockStoreFail{} },
+			method: http.MethodGet, path: "/v1/feed", token: token,
+			status: http.StatusInternalServerError, code: "internal", msg: "internal error"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.setup != nil {
+				tc.setup()
+			}
+			req, _ := http.NewRequest(tc.method, ts.URL+tc.path, bytes.NewBufferString(tc.body))
+			req.Header.Set("X-Request-ID", "req-"+strconv.Itoa(tc.status))
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var body struct {
+				Code      string `json:"code"`
+				Message   string `json:"message"`
+				RequestID string `json:"request_id"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if resp.StatusCode != tc.status || body.Code != tc.code || body.Message != tc.msg {
+				t.Fatalf("expected %d %s %q, got %d %+v", tc.status, tc.code, tc.msg, resp.StatusCode, body)
+			}
+			if want := "req-" + strconv.Itoa(tc.status); body.RequestID != want || resp.Header.Get("X-Request-ID") != want {
+				t.Fatalf("expected request ID %q, got body %q header %q", want, body.RequestID, resp.Header.Get("X-Request-ID"))
+			}
+		})
+	}
+}
+
+// malformed cursor is rejected
+func TestFeed_InvalidCursor(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	_, ts := setupTestServer(t)
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/feed?cursor=%21%21", nil)
+	req.Header.Set("Authorization", "Bearer "+makeTestJWT("1"))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Do request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
 // invalid JSON for creating user
 func TestCreateUser_InvalidJSON(t *testing.T) {
 	_, ts := setupTestServer(t)
 	defer ts.Close()
 
 	body := []byte(`{"username":123}`)
-	resp, err := http.Post(ts.URL+"/users", "application/json", bytes.NewBuffer(body))
+	resp, err := http.Post(ts.URL+"/v1/users", "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("http.Post failed: %v", err)
 	}
@@ -160,6 +956,93 @@ func TestCreateUser_InvalidJSON(t *testing.T) {
 	}
 }
 
+// follow requests are validated before they reach the store
+func TestFollow_Validation(t *testing.T) {
+	os.Setenv("JWT_SECRET", "test-secret")
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	almazID, _ := s.store.CreateUser("almaz", "")
+	nurID, _ := s.store.CreateUser("nur", "")
+	token := makeTestJWT(almazID)
+
+	// Cases run in order: the last one repeats the follow made by the one before.
+	cases := []struct {
+		name       string
+		followeeID string
+		status     int
+		code       string
+	}{
+		{"empty", "", http.StatusBadRequest, "invalid_request"},
+		{"not a uuid", "nur", http.StatusBadRequest, "invalid_request"},
+		{"self follow", almazID, http.StatusBadRequest, "invalid_request"},
+		{"unknown user", uuid.NewString(), http.StatusNotFound, "not_found"},
+		{"valid", nurID, http.StatusOK, ""},
+		{"already following", nurID, http.StatusConflict, "conflict"},
+	}
+
+	for _, tc := range cases {
+		resp := sendJSONRequest(t, http.MethodPost, ts.URL+"/v1/follow", map[string]any{"followee_id": tc.followeeID}, token, tc.status)
+		var body struct {
+			Code string `json:"code"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		resp.Body.Close()
+		if body.Code != tc.code {
+			t.Fatalf("%s: expected code %q, got %q", tc.name, tc.code, body.Code)
+		}
+	}
+
+	if followers, _ := s.store.GetFollowers(nurID); len(followers) != 1 || followers[0] != almazID {
+		t.Fatalf("expected exactly one follow to be stored, got %v", followers)
+	}
+	if followers, _ := s.store.GetFollowers(almazID); len(followers) != 0 {
+		t.Fatalf("expected no self-follow, got %v", followers)
+	}
+}
+
+// concurrent follows of the same user: exactly one succeeds, the rest get 409
+func TestFollow_Concurrent(t *testing.T) {
+	s, ts := setupTestServer(t)
+	defer ts.Close()
+
+	almazID, _ := s.store.CreateUser("almaz", "")
+	nurID, _ := s.store.CreateUser("nur", "")
+	token := makeTestJWT(almazID)
+
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/follow", strings.NewReader(`{"followee_id":"`+nurID+`"}`))
+			req.Header.Set("Authorization", "Bearer "+token)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				statuses <- 0
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusOK] != 1 || counts[http.StatusConflict] != attempts-1 {
+		t.Fatalf("expected one 200 and %d 409s, got %v", attempts-1, counts)
+	}
+	if followers, _ := s.store.GetFollowers(nurID); len(followers) != 1 {
+		t.Fatalf("expected exactly one follow to be stored, got %v", followers)
+	}
+}
+
 // invalid JSON for follow
 func TestFollow_InvalidJSON(t *testing.T) {
 	os.Setenv("JWT_SECRET", "test-secret")
@@ -169,7 +1052,7 @@ func TestFollow_InvalidJSON(t *testing.T) {
 	token := makeTestJWT("1")
 	body := []byte(`{"followee_id":1}`)
 
-	req, err := http.NewRequest(http.MethodPost, ts.URL+"/follow", bytes.NewBuffer(body))
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/follow", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("NewRequest failed: %v", err)
 	}
@@ -186,14 +1069,21 @@ func TestFollow_InvalidJSON(t *testing.T) {
 	}
 }
 
-// Kafka write error
+// Kafka write error keeps the event in the outbox
 func TestKafkaWriteError(t *testing.T) {
-	s, _ := setupTestServer(t)
-	s.kafkaWriter = &appkafka.MockKafkaFail{}
+	mockStore := store.NewMock()
+	post := models.Post{ID: "p1", AuthorID: "a1", Body: "pending", Created: time.Now()}
+	if err := mockStore.AddPostWithOutbox(post, models.OutboxEvent{Key: "post_created", Payload: []byte(`{}`)}); err != nil {
+		t.Fatalf("AddPostWithOutbox failed: %v", err)
+	}
 
-	if err := s.kafkaWriter.WriteMessages(kafka.Message{Key: []byte("k"), Value: []byte("v")}); err == nil {
+	r := relay.New(mockStore, &appkafka.MockKafkaFail{}, 0, 0)
+	if _, err := r.Flush(context.Background()); err == nil {
 		t.Fatalf("expected error from MockKafkaFail")
 	}
+	if len(mockStore.Outbox) != 1 {
+		t.Fatalf("expected event to stay in outbox, got %d", len(mockStore.Outbox))
+	}
 }
 
 // Store create user failure
@@ -201,7 +1091,7 @@ func TestStoreCreateUserFail(t *testing.T) {
 	s, _ := setupTestServer(t)
 	s.store = &store.MockStoreFail{}
 
-	if _, err := s.store.CreateUser("almaz"); err == nil {
+	if _, err := s.store.CreateUser("almaz", ""); err == nil {
 		t.Fatalf("expected error from MockStoreFail")
 	}
 }
@@ -210,11 +1100,34 @@ func TestStoreCreateUserFail(t *testing.T) {
 // --- Helpers for test logic ---
 //
 
+// helper: write a private key as a PKCS#8 PEM file named after its key ID
+// testKeys returns the HS256 key set the test tokens are signed with.
+func testKeys(t *testing.T) *jwtkeys.KeySet {
+	t.Helper()
+	keys, err := jwtkeys.NewHMAC([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("NewHMAC failed: %v", err)
+	}
+	return keys
+}
+
+func writeKeyFile(t *testing.T, dir, kid string, key any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey failed: %v", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(dir, kid+".pem"), data, 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+}
+
 // helper: create a new user
 func createUserHelper(ts *httptest.Server, name string, t *testing.T) string {
 	t.Helper()
-	body := []byte(`{"username":"` + name + `"}`)
-	resp, err := http.Post(ts.URL+"/users", "application/json", bytes.NewBuffer(body))
+	body := []byte(`{"username":"` + name + `","password":"` + testPassword + `"}`)
+	resp, err := http.Post(ts.URL+"/v1/users", "application/json", bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("createUser failed: %v", err)
 	}
@@ -234,11 +1147,30 @@ func createUserHelper(ts *httptest.Server, name string, t *testing.T) string {
 	}
 }
 
+// helper: poll the feed until cond holds or fail after a second
+func waitForFeed(t *testing.T, ts *httptest.Server, token string, cond func([]models.Post) bool) {
+	t.Helper()
+	deadline := time.Now().Add(1 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond(getFeedHelper(t, ts, token)) {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("feed did not reach expected state: %+v", getFeedHelper(t, ts, token))
+}
+
 // helper: get user feed using JWT token
 func getFeedHelper(t *testing.T, ts *httptest.Server, token string) []models.Post {
 	t.Helper()
+	return getFeedPageHelper(t, ts, token, "").Posts
+}
+
+// helper: get one feed page for the given query string (e.g. "?limit=2&cursor=...")
+func getFeedPageHelper(t *testing.T, ts *httptest.Server, token, query string) feedResponse {
+	t.Helper()
 
-	req, err := http.NewRequest(http.MethodGet, ts.URL+"/feed", nil)
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/feed"+query, nil)
 	if err != nil {
 		t.Fatalf("NewRequest failed: %v", err)
 	}
@@ -250,7 +1182,7 @@ func getFeedHelper(t *testing.T, ts *httptest.Server, token string) []models.Pos
 	}
 	defer resp.Body.Close()
 
-	var posts []models.Post
-	_ = json.NewDecoder(resp.Body).Decode(&posts)
-	return posts
+	var page feedResponse
+	_ = json.NewDecoder(resp.Body).Decode(&page)
+	return page
 }
@@ -0,0 +1,234 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"example.com/cassandrafeed/internal/apierr"
+	"example.com/cassandrafeed/internal/jwtkeys"
+	"example.com/cassandrafeed/internal/logger"
+	"example.com/cassandrafeed/internal/middleware"
+	"example.com/cassandrafeed/internal/models"
+	"example.com/cassandrafeed/internal/store"
+	"github.com/gocql/gocql"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Sessions: a login starts a session and yields a short-lived access token
+// (a JWT carrying the session ID) and a refresh token. Refresh tokens are
+// random strings stored in Cassandra only as their SHA-256 hash; each one is
+// exchanged exactly once for a new pair of the same session. Presenting an
+// exchanged refresh token again means it leaked, so its session is revoked.
+// Logging out revokes the session, which rejects its refresh token and all
+// access tokens issued for it.
+
+// sessionResponse carries the tokens issued on registration, login and refresh.
+type sessionResponse struct {
+	UserID       string `json:"user_id"`
+	Token        string `json:"token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshRequest is the body of refresh requests.
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshHandler exchanges a refresh token for a new access and refresh token.
+// Expects JSON body: {"refresh_token": "..."}
+// Returns the same JSON response as login, or 401 if the refresh token is
+// unknown, expired or already used. A reused refresh token also revokes its
+// session.
+func (s *Server) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	var body refreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		logg.InfoContext(r.Context(), "http/sessions", "Invalid refresh request body")
+		apierr.Write(w, r, apierr.BadRequest("refresh_token is required"))
+		return
+	}
+	defer r.Body.Close()
+
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to generate refresh token", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	sess, err := s.storeFor(r).RotateRefreshToken(hashRefreshToken(body.RefreshToken), hashRefreshToken(refreshToken), s.refreshTokenTTL)
+	if errors.Is(err, store.ErrRefreshTokenReused) {
+		logg.WarnContext(r.Context(), "http/sessions", "Refresh token reused, revoking its session", logger.UserRef(sess.UserID))
+		if err := s.revokeSession(r, sess); err != nil {
+			logg.ErrorContext(r.Context(), "http/sessions", "Failed to revoke session of reused refresh token", err)
+			apierr.Write(w, r, err)
+			return
+		}
+		apierr.Write(w, r, apierr.Unauthorized("invalid refresh token"))
+		return
+	}
+	if errors.Is(err, gocql.ErrNotFound) {
+		logg.InfoContext(r.Context(), "http/sessions", "Rejected unknown or expired refresh token")
+		apierr.Write(w, r, apierr.Unauthorized("invalid refresh token"))
+		return
+	}
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to rotate refresh token", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	logg.InfoContext(r.Context(), "http/sessions", "Session refreshed", logger.UserRef(sess.UserID))
+	s.writeSession(w, r, http.StatusOK, sess, refreshToken)
+}
+
+// logoutHandler ends the caller's session: its refresh token and every
+// access token issued for it stop working immediately.
+// Returns 204 No Content.
+func (s *Server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.UserIDFromContext(r.Context())
+	tok, ok := middleware.TokenFromContext(r.Context())
+	if !ok {
+		logg.InfoContext(r.Context(), "http/sessions", "Unauthorized logout attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
+		return
+	}
+
+	if err := s.revokeSession(r, models.Session{ID: tok.SessionID, UserID: userID}); err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to revoke session", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	logg.InfoContext(r.Context(), "http/sessions", "User logged out", logger.UserRef(userID))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// logoutAllHandler ends every session of the caller, e.g. after a device
+// was lost or the password leaked.
+// Returns 204 No Content.
+func (s *Server) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		logg.InfoContext(r.Context(), "http/sessions", "Unauthorized logout attempt")
+		apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
+		return
+	}
+
+	ids, err := s.storeFor(r).RevokeAllSessions(userID)
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to revoke sessions", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	// Reject the access tokens of the sessions, including the caller's
+	until := time.Now().Add(s.accessTokenTTL)
+	if tok, ok := middleware.TokenFromContext(r.Context()); ok && !slices.Contains(ids, tok.SessionID) {
+		ids = append(ids, tok.SessionID)
+	}
+	for _, id := range ids {
+		if err := s.revocations.RevokeSession(id, until); err != nil {
+			logg.ErrorContext(r.Context(), "http/sessions", "Failed to revoke access tokens of session", err)
+			apierr.Write(w, r, err)
+			return
+		}
+	}
+
+	logg.InfoContext(r.Context(), "http/sessions", "User logged out of all sessions", logger.UserRef(userID), "sessions", len(ids))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeSession ends sess: its refresh token stops working at once, and so
+// do its access tokens, which would otherwise live up to accessTokenTTL.
+func (s *Server) revokeSession(r *http.Request, sess models.Session) error {
+	if err := s.storeFor(r).RevokeSession(sess.UserID, sess.ID); err != nil {
+		return err
+	}
+	return s.revocations.RevokeSession(sess.ID, time.Now().Add(s.accessTokenTTL))
+}
+
+// startSession starts a new session for userID and writes its tokens with status.
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, status int, userID string) {
+	refreshToken, err := newRefreshToken()
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to generate refresh token", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	sess := models.Session{ID: uuid.NewString(), UserID: userID}
+	if err := s.storeFor(r).CreateSession(sess, hashRefreshToken(refreshToken), s.refreshTokenTTL); err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to create session", err)
+		apierr.Write(w, r, err)
+		return
+	}
+	s.writeSession(w, r, status, sess, refreshToken)
+}
+
+// writeSession issues an access token for sess and writes it together with
+// the session's refresh token.
+func (s *Server) writeSession(w http.ResponseWriter, r *http.Request, status int, sess models.Session, refreshToken string) {
+	accessToken, err := s.signAccessToken(sess)
+	if err != nil {
+		logg.ErrorContext(r.Context(), "http/sessions", "Failed to sign token", err)
+		apierr.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(sessionResponse{
+		UserID:       sess.UserID,
+		Token:        accessToken,
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+	})
+}
+
+// signAccessToken mints an access token for the user of sess with a unique
+// jti, so it can be revoked on its own, and the session ID.
+func (s *Server) signAccessToken(sess models.Session) (string, error) {
+	now := time.Now()
+	return s.keys.Sign(jwt.MapClaims{
+		"user_id": sess.UserID,
+		"sid":     sess.ID,
+		"jti":     uuid.NewString(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.accessTokenTTL).Unix(),
+	})
+}
+
+// jwksHandler publishes the public keys that verify our access tokens, so
+// other services can check them without sharing a secret.
+// Path: /.well-known/jwks.json
+func (s *Server) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(jwtkeys.PublishDelay.Seconds())))
+	json.NewEncoder(w).Encode(s.keys.JWKS())
+}
+
+// newRefreshToken returns a random, URL-safe refresh token.
+func newRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken is the form in which refresh tokens are stored, so a
+// leaked table does not leak usable tokens.
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"example.com/cassandrafeed/internal/store"
+)
+
+// Ledger remembers which events the worker has fully processed, so a
+// message redelivered after a rebalance or crash is skipped instead of
+// fanned out again. Processed event IDs live in Cassandra with a TTL; an
+// in-memory LRU in front of it answers recent redeliveries without a query.
+// Events that failed midway are never marked, so their redelivery simply
+// resumes the fan-out, whose feed inserts are idempotent.
+type Ledger struct {
+	store store.StoreInterface
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	capacity int
+	order    *list.List               // most recently used event IDs first
+	entries  map[string]*list.Element // event ID -> element in order
+}
+
+// NewLedger creates a Ledger that keeps entries for ttl and caches up to
+// cacheSize event IDs in memory.
+func NewLedger(st store.StoreInterface, ttl time.Duration, cacheSize int) *Ledger {
+	if ttl <= 0 {
+		ttl = 7 * 24 * time.Hour
+	}
+	if cacheSize <= 0 {
+		cacheSize = 10000
+	}
+	return &Ledger{
+		store:    st,
+		ttl:      ttl,
+		capacity: cacheSize,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Processed reports whether the event has already been handled.
+func (l *Ledger) Processed(eventID string) (bool, error) {
+	if l.cached(eventID) {
+		return true, nil
+	}
+
+	done, err := l.store.IsEventProcessed(eventID)
+	if err != nil {
+		return false, err
+	}
+	if done {
+		l.remember(eventID)
+	}
+	return done, nil
+}
+
+// MarkProcessed records that the event has been handled completely.
+func (l *Ledger) MarkProcessed(eventID string) error {
+	if err := l.store.MarkEventProcessed(eventID, l.ttl); err != nil {
+		return err
+	}
+	l.remember(eventID)
+	return nil
+}
+
+func (l *Ledger) cached(eventID string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.entries[eventID]
+	if ok {
+		l.order.MoveToFront(el)
+	}
+	return ok
+}
+
+func (l *Ledger) remember(eventID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.entries[eventID]; ok {
+		l.order.MoveToFront(el)
+		return
+	}
+	l.entries[eventID] = l.order.PushFront(eventID)
+	if l.order.Len() > l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(string))
+	}
+}
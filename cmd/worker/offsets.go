@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	appkafka "example.com/cassandrafeed/internal/broker"
+	"github.com/segmentio/kafka-go"
+)
+
+// commitTimeout bounds an offset commit, which may run after shutdown has begun.
+const commitTimeout = 5 * time.Second
+
+// partitionKey identifies a topic partition.
+type partitionKey struct {
+	topic     string
+	partition int
+}
+
+// partitionOffsets tracks fetched messages of one partition in fetch order.
+type partitionOffsets struct {
+	pending []int64                 // fetched, not yet committed offsets, oldest first
+	done    map[int64]kafka.Message // finished messages waiting for earlier ones
+}
+
+// offsetTracker decides which offsets are safe to commit. Messages are
+// processed concurrently and may finish out of order, but a partition's
+// offset may only advance past a message once every earlier message of that
+// partition has finished too.
+//
+// When a consumer group generation ends, the reader resumes each partition
+// it is (re)assigned from the committed offset, so a fetched offset at or
+// below one already tracked marks a new generation. The partition's state is
+// then dropped: the messages of the old generation are fetched again, and
+// completions of the old copies are ignored.
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[partitionKey]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[partitionKey]*partitionOffsets)}
+}
+
+// track registers a fetched message before it is handed to processing.
+func (t *offsetTracker) track(msg kafka.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := partitionKey{msg.Topic, msg.Partition}
+	p, ok := t.partitions[key]
+	if !ok || (len(p.pending) > 0 && msg.Offset <= p.pending[len(p.pending)-1]) {
+		p = &partitionOffsets{done: make(map[int64]kafka.Message)}
+		t.partitions[key] = p
+	}
+	p.pending = append(p.pending, msg.Offset)
+}
+
+// complete marks a message finished and returns the newest message whose
+// offset can now be committed, if the partition's finished prefix advanced.
+func (t *offsetTracker) complete(msg kafka.Message) (kafka.Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[partitionKey{msg.Topic, msg.Partition}]
+	if !ok {
+		return kafka.Message{}, false
+	}
+	// Offsets of a previous generation are no longer pending
+	if _, tracked := slices.BinarySearch(p.pending, msg.Offset); !tracked {
+		return kafka.Message{}, false
+	}
+	p.done[msg.Offset] = msg
+
+	var last kafka.Message
+	advanced := false
+	for len(p.pending) > 0 {
+		m, finished := p.done[p.pending[0]]
+		if !finished {
+			break
+		}
+		delete(p.done, p.pending[0])
+		p.pending = p.pending[1:]
+		last, advanced = m, true
+	}
+	return last, advanced
+}
+
+// source couples a reader with the tracker of the messages fetched from it,
+// so that completed messages are committed on the reader they came from.
+type source struct {
+	reader    appkafka.KafkaReader
+	tracker   *offsetTracker
+	commitMu  sync.Mutex // serializes commits so offsets never move backwards
+	heartbeat heartbeat  // progress of the read loop consuming the reader
+}
+
+func newSource(reader appkafka.KafkaReader) *source {
+	return &source{reader: reader, tracker: newOffsetTracker()}
+}
+
+// done marks a message as fully processed and commits the partition offset
+// as far as in-order completion allows.
+func (s *source) done(msg kafka.Message) {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+
+	commit, ok := s.tracker.complete(msg)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+	defer cancel()
+	if err := s.reader.CommitMessages(ctx, commit); err != nil {
+		logg.Error("worker", "Failed to commit Kafka offset", err)
+	}
+}
@@ -0,0 +1,26 @@
+package worker
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// defaultStallTimeout is how long a read loop may go without progress
+// before the worker reports itself not ready.
+const defaultStallTimeout = time.Minute
+
+// heartbeat records when a read loop is next expected to make progress:
+// fetch a message, hand it to the processors, or find its topic idle.
+type heartbeat struct {
+	deadline atomic.Int64 // Unix nanoseconds
+}
+
+// beat pushes the deadline to d from now.
+func (h *heartbeat) beat(d time.Duration) {
+	h.deadline.Store(time.Now().Add(d).UnixNano())
+}
+
+// overdue reports whether the deadline has passed without a beat.
+func (h *heartbeat) overdue() bool {
+	return time.Now().UnixNano() > h.deadline.Load()
+}
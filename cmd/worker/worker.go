@@ -2,7 +2,7 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"runtime"
@@ -10,9 +10,16 @@ import (
 	"time"
 
 	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/events"
 	"example.com/cassandrafeed/internal/logger"
+	"example.com/cassandrafeed/internal/metrics"
 	"example.com/cassandrafeed/internal/models"
 	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
+	"github.com/gocql/gocql"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var logg = logger.New()
@@ -23,8 +30,68 @@ type Worker struct {
 	reader       appkafka.KafkaReader
 	workerCount  int
 	jobQueueSize int
+	retry        RetryPolicy
+	fanout       FanoutPolicy
+	ledger       *Ledger
+
+	registryOnce sync.Once
+	registry     *events.Registry
+
+	// Read loops report progress to their source's heartbeat
+	stallTimeout time.Duration
+	sourcesMu    sync.Mutex
+	sources      []*source
 }
 
+// RetryPolicy controls how messages that fail processing are retried and dead-lettered.
+// The zero value disables both, so failed messages are only logged.
+type RetryPolicy struct {
+	MaxAttempts int                  // processing attempts before a message is dead-lettered
+	Backoff     time.Duration        // delay before the first retry, doubled on every further retry
+	RetryReader appkafka.KafkaReader // consumer of the retry topic
+	RetryWriter appkafka.KafkaWriter // producer to the retry topic; nil sends failures straight to the DLQ
+	DLQWriter   appkafka.KafkaWriter // producer to the dead-letter topic; nil drops failed messages
+}
+
+// FanoutPolicy controls how a post is written to its followers' feeds.
+// Zero fields fall back to the defaults.
+type FanoutPolicy struct {
+	Concurrency int // follower batches written in parallel (default 20)
+	BatchSize   int // followers per Store.AddToFeeds call (default 50)
+}
+
+// withDefaults fills unset fields of the policy.
+func (p FanoutPolicy) withDefaults() FanoutPolicy {
+	if p.Concurrency <= 0 {
+		p.Concurrency = 20
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = 50
+	}
+	return p
+}
+
+// backoff returns the delay before the given attempt number is retried.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	base := p.Backoff
+	if base <= 0 {
+		base = time.Second
+	}
+	return base * time.Duration(math.Pow(2, float64(attempt-1)))
+}
+
+// job is a fetched message together with the source it must be committed on.
+type job struct {
+	msg kafka.Message
+	src *source
+}
+
+// permanentError marks failures that retrying cannot fix, such as malformed payloads.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
 // New creates a new concurrent Worker using pre-initialized dependencies.
 func New(store store.StoreInterface, reader appkafka.KafkaReader, workerCount, jobQueueSize int) *Worker {
 	if workerCount <= 0 {
@@ -38,9 +105,69 @@ func New(store store.StoreInterface, reader appkafka.KafkaReader, workerCount, j
 		reader:       reader,
 		workerCount:  workerCount,
 		jobQueueSize: jobQueueSize,
+		stallTimeout: defaultStallTimeout,
 	}
 }
 
+// WithRetryPolicy enables the retry and dead-letter pipeline for failed messages.
+func (w *Worker) WithRetryPolicy(p RetryPolicy) *Worker {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	w.retry = p
+	return w
+}
+
+// WithFanoutPolicy configures the concurrency and batch size of feed fan-out.
+func (w *Worker) WithFanoutPolicy(p FanoutPolicy) *Worker {
+	w.fanout = p
+	return w
+}
+
+// WithLedger makes the worker skip events the ledger has already seen processed.
+func (w *Worker) WithLedger(l *Ledger) *Worker {
+	w.ledger = l
+	return w
+}
+
+// WithStallTimeout sets how long a read loop may go without progress before
+// CheckProgress fails. Zero keeps the default of one minute.
+func (w *Worker) WithStallTimeout(d time.Duration) *Worker {
+	if d > 0 {
+		w.stallTimeout = d
+	}
+	return w
+}
+
+// CheckProgress fails if the worker has not started reading yet or one of its
+// read loops is stuck: blocked on a full job queue, or failing to fetch, for
+// longer than the stall timeout. A loop waiting on an idle topic is fine.
+func (w *Worker) CheckProgress(ctx context.Context) error {
+	w.sourcesMu.Lock()
+	defer w.sourcesMu.Unlock()
+
+	if len(w.sources) == 0 {
+		return errors.New("worker has not started reading")
+	}
+	for _, src := range w.sources {
+		if src.heartbeat.overdue() {
+			return fmt.Errorf("read loop made no progress for over %s", w.stallTimeout)
+		}
+	}
+	return nil
+}
+
+// addSource registers a source whose read loop is about to start.
+func (w *Worker) addSource(reader appkafka.KafkaReader) *source {
+	src := newSource(reader)
+	src.heartbeat.beat(w.stallTimeout)
+
+	w.sourcesMu.Lock()
+	defer w.sourcesMu.Unlock()
+	w.sources = append(w.sources, src)
+	return src
+}
+
 // Run starts message reading and concurrent processing.
 func (w *Worker) Run(ctx context.Context) {
 	if w.workerCount <= 0 {
@@ -49,10 +176,13 @@ func (w *Worker) Run(ctx context.Context) {
 	if w.jobQueueSize <= 0 {
 		w.jobQueueSize = 10
 	}
+	if w.stallTimeout <= 0 {
+		w.stallTimeout = defaultStallTimeout
+	}
 
-	logg.Info("worker", "Starting "+fmt.Sprint(w.workerCount)+" workers with queue size "+fmt.Sprint(w.jobQueueSize))
+	logg.Info("worker", "Starting workers", "workers", w.workerCount, "queue_size", w.jobQueueSize)
 
-	jobs := make(chan []byte, w.jobQueueSize)
+	jobs := make(chan job, w.jobQueueSize)
 	var wg sync.WaitGroup
 
 	for i := 0; i < w.workerCount; i++ {
@@ -63,22 +193,51 @@ func (w *Worker) Run(ctx context.Context) {
 		}(i)
 	}
 
-	w.readLoop(ctx, jobs)
+	// Main topic and retry topic feed the same job queue
+	var readers sync.WaitGroup
+	readers.Add(1)
+	mainSrc := w.addSource(w.reader)
+	go func() {
+		defer readers.Done()
+		w.readLoop(ctx, mainSrc, jobs)
+	}()
+	if w.retry.RetryReader != nil {
+		readers.Add(1)
+		retrySrc := w.addSource(w.retry.RetryReader)
+		go func() {
+			defer readers.Done()
+			w.readLoop(ctx, retrySrc, jobs)
+		}()
+	}
+	readers.Wait()
 
 	close(jobs)
 	wg.Wait()
 	logg.Info("worker", "All workers stopped gracefully")
 }
 
-// readLoop reads Kafka messages and pushes them into a job queue.
-func (w *Worker) readLoop(ctx context.Context, jobs chan<- []byte) {
+// readLoop fetches Kafka messages and pushes them into a job queue.
+// Offsets are not committed here but only once processing has finished.
+// Messages scheduled for a later retry are held back until their time has come.
+// Every fetch, hand-off and idle poll beats the source's heartbeat; fetch
+// errors and a full queue do not.
+func (w *Worker) readLoop(ctx context.Context, src *source, jobs chan<- job) {
 	var retry int
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			msg, err := w.reader.ReadMessage(ctx)
+			// Bound the fetch so an idle topic still counts as progress
+			fetchCtx, cancel := context.WithTimeout(ctx, w.stallTimeout/2)
+			msg, err := src.reader.FetchMessage(fetchCtx)
+			// A message returned as the deadline passes must still be handled
+			idle := err != nil && errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+			cancel()
+			if idle {
+				src.heartbeat.beat(w.stallTimeout)
+				continue
+			}
 			if err != nil {
 				backoff := time.Duration(math.Min(1000, math.Pow(2, float64(retry)))) * time.Millisecond
 				logg.Error("worker", "Kafka read error, backing off", err)
@@ -89,6 +248,8 @@ func (w *Worker) readLoop(ctx context.Context, jobs chan<- []byte) {
 				continue
 			}
 			retry = 0
+			src.heartbeat.beat(w.stallTimeout)
+			metrics.SetConsumerLag(msg.Topic, msg.Partition, msg.HighWaterMark-msg.Offset-1)
 
 			if len(msg.Value) == 0 {
 				if !waitWithContext(ctx, 50*time.Millisecond) {
@@ -97,66 +258,286 @@ func (w *Worker) readLoop(ctx context.Context, jobs chan<- []byte) {
 				continue
 			}
 
-			select {
-			case jobs <- msg.Value:
-			case <-ctx.Done():
+			if notBefore := appkafka.RetryNotBefore(msg); !notBefore.IsZero() {
+				src.heartbeat.beat(time.Until(notBefore) + w.stallTimeout)
+				if !waitWithContext(ctx, time.Until(notBefore)) {
+					return
+				}
+			}
+
+			src.tracker.track(msg)
+			if !enqueue(ctx, jobs, job{msg: msg, src: src}) {
 				return
-			case <-time.After(100 * time.Millisecond):
-				logg.Info("worker", "Queue full, waiting to enqueue Kafka message")
 			}
+			src.heartbeat.beat(w.stallTimeout)
+		}
+	}
+}
+
+// enqueue blocks until the message is queued or the context is cancelled.
+func enqueue(ctx context.Context, jobs chan<- job, j job) bool {
+	for {
+		select {
+		case jobs <- j:
+			return true
+		case <-ctx.Done():
+			return false
+		case <-time.After(100 * time.Millisecond):
+			logg.Warn("worker", "Queue full, waiting to enqueue Kafka message")
 		}
 	}
 }
 
-// processLoop handles JSON decoding and feed updates concurrently.
-func (w *Worker) processLoop(ctx context.Context, jobs <-chan []byte) {
+// processLoop handles messages from the job queue, routes failures to the
+// retry or dead-letter topic and commits a message only once it is fully
+// handled, so a crash mid-fan-out leads to redelivery instead of a lost post.
+func (w *Worker) processLoop(ctx context.Context, jobs <-chan job) {
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case data, ok := <-jobs:
+		case j, ok := <-jobs:
 			if !ok {
 				return
 			}
 
-			var post models.Post
-			if err := json.Unmarshal(data, &post); err != nil {
-				logg.Error("worker", "Invalid JSON in Kafka message", err)
-				continue
+			if err := w.process(ctx, j.msg); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				// The offset must not be skipped, and kafka-go does not fetch
+				// it again before a restart: keep handing it off until the
+				// retry or dead-letter topic accepts it
+				for attempt := 0; !w.handleFailure(j.msg, err); attempt++ {
+					if !waitWithContext(ctx, handOffBackoff(attempt)) {
+						return
+					}
+				}
+			} else {
+				metrics.CountMessage(j.msg.Topic, "processed")
 			}
+			j.src.done(j.msg)
+		}
+	}
+}
 
-			followers, err := w.store.GetFollowers(post.AuthorID)
-			if err != nil {
-				logg.Error("worker", "Error fetching followers for post author", err)
-				continue
-			}
+// process handles msg in a consumer span that continues the trace found in
+// its headers, so the fan-out joins the trace of the request that created it.
+func (w *Worker) process(ctx context.Context, msg kafka.Message) error {
+	ctx, span := tracing.StartKafka(tracing.ExtractKafka(ctx, msg), trace.SpanKindConsumer, "process", msg)
+	err := w.handleMessage(ctx, msg)
+	tracing.End(span, err)
+	return err
+}
 
-			const fanoutLimit = 20
-			var fanoutWG sync.WaitGroup
-			semaphore := make(chan struct{}, fanoutLimit)
+// handleMessage decodes the event envelope and dispatches it to the handler
+// registered for its type and schema version. Envelopes that can never be
+// handled (malformed, unknown type or version) are marked permanent so they
+// go straight to the dead-letter topic. Events the ledger has already seen
+// processed are skipped.
+func (w *Worker) handleMessage(ctx context.Context, msg kafka.Message) error {
+	w.registryOnce.Do(func() { w.registry = w.newRegistry() })
 
-			for _, uid := range followers {
-				select {
-				case <-ctx.Done():
-					return
-				default:
-					fanoutWG.Add(1)
-					semaphore <- struct{}{}
-
-					go func(u string) {
-						defer fanoutWG.Done()
-						defer func() { <-semaphore }()
-						if err := w.store.AddToFeed(u, post); err != nil {
-							logg.Error("worker", "Failed to add post to user feed", err)
-						}
-					}(uid)
-				}
+	env, err := events.Decode(msg.Value)
+	if err != nil {
+		return permanentError{err}
+	}
+
+	track := w.ledger != nil && env.ID != ""
+	if track {
+		done, err := w.ledger.Processed(env.ID)
+		if err != nil {
+			return fmt.Errorf("check processed events: %w", err)
+		}
+		if done {
+			logg.InfoContext(ctx, "worker", "Skipping already processed event", "event_id", env.ID)
+			return nil
+		}
+	}
+
+	if err := w.registry.DispatchEnvelope(ctx, env); err != nil {
+		if events.IsRejected(err) {
+			return permanentError{err}
+		}
+		return err
+	}
+
+	if track {
+		// The event is done either way; a missing entry only means a
+		// redelivery repeats the idempotent fan-out.
+		if err := w.ledger.MarkProcessed(env.ID); err != nil {
+			logg.ErrorContext(ctx, "worker", "Failed to record processed event", err, "event_id", env.ID)
+		}
+	}
+	return nil
+}
+
+// newRegistry registers the worker's handler for every supported event version.
+func (w *Worker) newRegistry() *events.Registry {
+	r := events.NewRegistry()
+	events.Handle(r, events.TypePostCreated, 1, w.handlePostCreated)
+	events.Handle(r, events.TypePostUpdated, 1, w.handlePostUpdated)
+	events.Handle(r, events.TypePostDeleted, 1, w.handlePostDeleted)
+	events.Handle(r, events.TypeFollowDeleted, 1, w.handleFollowDeleted)
+	return r
+}
+
+// handleFollowDeleted purges the unfollowed author's posts from the follower's feed.
+func (w *Worker) handleFollowDeleted(ctx context.Context, env events.Envelope, follow models.Follow) error {
+	if err := store.WithTrace(w.store, ctx).RemoveAuthorFromFeed(follow.UserID, follow.FolloweeID); err != nil {
+		return fmt.Errorf("remove author from feed: %w", err)
+	}
+	logg.InfoContext(ctx, "worker", "Unfollowed author's posts removed from feed (IDs anonymized)", "event_id", env.ID)
+	return nil
+}
+
+// handlePostCreated fans a new post out to every follower's feed, unless
+// the post no longer exists.
+func (w *Worker) handlePostCreated(ctx context.Context, env events.Envelope, post models.Post) error {
+	// A deletion may overtake its creation through retries; fanning out a
+	// deleted post would leave it in feeds for good.
+	if _, err := store.WithTrace(w.store, ctx).GetPost(post.ID); errors.Is(err, gocql.ErrNotFound) {
+		logg.InfoContext(ctx, "worker", "Post deleted before fan-out, skipped (post ID anonymized)", "event_id", env.ID)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("look up post: %w", err)
+	}
+
+	if err := w.fanOut(ctx, post.AuthorID, func(st store.StoreInterface, uids []string) error {
+		return st.AddToFeeds(uids, post)
+	}); err != nil {
+		return fmt.Errorf("fan out post: %w", err)
+	}
+	logg.InfoContext(ctx, "worker", "Post delivered to followers (post ID anonymized)", "event_id", env.ID)
+	return nil
+}
+
+// handlePostUpdated rewrites an edited post in the feeds that hold it.
+func (w *Worker) handlePostUpdated(ctx context.Context, env events.Envelope, post models.Post) error {
+	if err := w.fanOut(ctx, post.AuthorID, func(st store.StoreInterface, uids []string) error {
+		return st.UpdateInFeeds(uids, post)
+	}); err != nil {
+		return fmt.Errorf("fan out post update: %w", err)
+	}
+	logg.InfoContext(ctx, "worker", "Updated post rewritten in followers' feeds (post ID anonymized)", "event_id", env.ID)
+	return nil
+}
+
+// handlePostDeleted removes a deleted post from every follower's feed.
+func (w *Worker) handlePostDeleted(ctx context.Context, env events.Envelope, post models.Post) error {
+	if err := w.fanOut(ctx, post.AuthorID, func(st store.StoreInterface, uids []string) error {
+		for _, uid := range uids {
+			if err := st.RemoveFromFeed(uid, post); err != nil {
+				return err
 			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("fan out post deletion: %w", err)
+	}
+	logg.InfoContext(ctx, "worker", "Deleted post removed from followers' feeds (post ID anonymized)", "event_id", env.ID)
+	return nil
+}
+
+// fanOut splits the followers of authorID into batches and applies fn to them
+// with bounded concurrency, returning the first error encountered. Celebrity
+// authors are skipped: their followers merge posts_by_author into the feed on read.
+// fn is given the store traced under the fan-out span.
+func (w *Worker) fanOut(ctx context.Context, authorID string, fn func(st store.StoreInterface, userIDs []string) error) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "fanout")
+	defer func() { tracing.End(span, err) }()
+	st := store.WithTrace(w.store, ctx)
+
+	celebrity, err := st.IsCelebrity(authorID)
+	if err != nil {
+		return fmt.Errorf("check celebrity author: %w", err)
+	}
+	if celebrity {
+		logg.InfoContext(ctx, "worker", "Skipping fan-out for celebrity author (author ID anonymized)")
+		return nil
+	}
+
+	followers, err := st.GetFollowers(authorID)
+	if err != nil {
+		return fmt.Errorf("fetch followers: %w", err)
+	}
+	span.SetAttributes(attribute.Int("feed.followers", len(followers)))
+
+	start := time.Now()
+	defer func() { metrics.ObserveFanout(len(followers), time.Since(start)) }()
+
+	policy := w.fanout.withDefaults()
+	var fanoutWG sync.WaitGroup
+	var errOnce sync.Once
+	var fanoutErr error
+	semaphore := make(chan struct{}, policy.Concurrency)
+
+	for i := 0; i < len(followers); i += policy.BatchSize {
+		batch := followers[i:min(i+policy.BatchSize, len(followers))]
 
+		select {
+		case <-ctx.Done():
 			fanoutWG.Wait()
-			logg.Info("worker", "Post delivered to followers (post ID anonymized)")
+			return ctx.Err()
+		default:
+			fanoutWG.Add(1)
+			semaphore <- struct{}{}
+
+			go func(uids []string) {
+				defer fanoutWG.Done()
+				defer func() { <-semaphore }()
+				if err := fn(st, uids); err != nil {
+					logg.ErrorContext(ctx, "worker", "Failed to update user feeds", err, "batch_size", len(uids))
+					errOnce.Do(func() { fanoutErr = err })
+				}
+			}(batch)
+		}
+	}
+
+	fanoutWG.Wait()
+	return fanoutErr
+}
+
+// handleFailure schedules a retry for a failed message or, once its attempts
+// are exhausted or the error is permanent, moves it to the dead-letter topic.
+// It reports whether the message was handed off and may be committed.
+func (w *Worker) handleFailure(msg kafka.Message, cause error) bool {
+	attempts := appkafka.RetryCount(msg) + 1
+	// Log under the message's trace, so the failure shows up next to it
+	ctx := tracing.ExtractKafka(context.Background(), msg)
+	fields := []any{"topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset}
+
+	var perm permanentError
+	if !errors.As(cause, &perm) && w.retry.RetryWriter != nil && attempts < w.retry.MaxAttempts {
+		notBefore := time.Now().Add(w.retry.backoff(attempts))
+		err := w.retry.RetryWriter.WriteMessages(appkafka.NewFailureMessage(msg, attempts, notBefore, cause))
+		if err == nil {
+			logg.ErrorContext(ctx, "worker", "Message processing failed, scheduled retry", cause, append(fields, "attempt", attempts)...)
+			metrics.CountMessage(msg.Topic, "retried")
+			return true
 		}
+		logg.ErrorContext(ctx, "worker", "Failed to publish message to retry topic", err, fields...)
+	}
+
+	if w.retry.DLQWriter == nil {
+		logg.ErrorContext(ctx, "worker", "Dropping failed message, no dead-letter topic configured", cause, fields...)
+		metrics.CountMessage(msg.Topic, "dropped")
+		return true
+	}
+	if err := w.retry.DLQWriter.WriteMessages(appkafka.NewFailureMessage(msg, attempts, time.Time{}, cause)); err != nil {
+		logg.ErrorContext(ctx, "worker", "Failed to publish message to dead-letter topic", err, fields...)
+		metrics.CountMessage(msg.Topic, "failed")
+		return false
 	}
+	logg.ErrorContext(ctx, "worker", "Message moved to dead-letter topic", cause, append(fields, "attempts", attempts)...)
+	metrics.CountMessage(msg.Topic, "dead_lettered")
+	return true
+}
+
+// handOffBackoff returns the delay before a failed hand-off to the retry or
+// dead-letter topic is attempted again: doubling from 100ms, up to 30s.
+func handOffBackoff(attempt int) time.Duration {
+	return min(100*time.Millisecond<<min(attempt, 9), 30*time.Second)
 }
 
 // waitWithContext waits for duration or context cancellation.
@@ -178,6 +559,12 @@ func (w *Worker) Close() error {
 		logg.Error("worker", "Error closing Kafka reader", err)
 		return err
 	}
+	if w.retry.RetryReader != nil {
+		if err := w.retry.RetryReader.Close(); err != nil {
+			logg.Error("worker", "Error closing Kafka retry reader", err)
+			return err
+		}
+	}
 
 	logg.Info("worker", "Closing Cassandra session")
 	w.store.Close()
@@ -2,10 +2,11 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
+	"sync"
 	"testing"
 	"time"
 
+	"example.com/cassandrafeed/internal/events"
 	"example.com/cassandrafeed/internal/models"
 	"example.com/cassandrafeed/internal/store"
 	"github.com/segmentio/kafka-go"
@@ -21,18 +22,19 @@ func TestWorker_GracefulShutdown(t *testing.T) {
 	authorID := "1"
 	followerID := "2"
 
-	mockStore.CreateUser("author")
-	mockStore.CreateUser("follower")
+	mockStore.CreateUser("author", "")
+	mockStore.CreateUser("follower", "")
 
 	mockStore.CreateFollow(followerID, authorID)
 
 	post := models.Post{
-		ID:       "100",
+		ID:       "00000000-0000-0000-0000-000000000100",
 		AuthorID: authorID,
 		Body:     "Shutdown test post",
 		Created:  time.Now(),
 	}
-	data, _ := json.Marshal(post)
+	mockStore.AddPost(post)
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
 
 	// Mock Kafka reader with a single message
 	mockKafka := &MockKafkaReader{
@@ -80,7 +82,9 @@ func TestWorker_GracefulShutdown(t *testing.T) {
 
 // MockKafkaReader simulates a Kafka reader for testing purposes
 type MockKafkaReader struct {
+	mu         sync.Mutex
 	Messages   []kafka.Message // Queue of messages to return
+	Committed  []kafka.Message // Messages committed via CommitMessages
 	ShouldFail bool            // If true, ReadMessage will fail
 	Closed     bool            // Tracks whether Close() has been called
 }
@@ -106,6 +110,32 @@ func (m *MockKafkaReader) ReadMessage(ctx context.Context) (kafka.Message, error
 	return msg, nil
 }
 
+// FetchMessage behaves like ReadMessage; commits are tracked separately
+func (m *MockKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return m.ReadMessage(ctx)
+}
+
+// CommitMessages records the committed messages
+func (m *MockKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Committed = append(m.Committed, msgs...)
+	return nil
+}
+
+// LastCommitted returns the highest committed offset, or -1 if nothing was committed
+func (m *MockKafkaReader) LastCommitted() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	last := int64(-1)
+	for _, msg := range m.Committed {
+		if msg.Offset > last {
+			last = msg.Offset
+		}
+	}
+	return last
+}
+
 // Close marks the mock Kafka reader as closed
 func (m *MockKafkaReader) Close() error {
 	m.Closed = true
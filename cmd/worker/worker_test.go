@@ -3,13 +3,24 @@ package worker
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/events"
 	"example.com/cassandrafeed/internal/models"
 	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
 	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // runWorkerOnce processes a single Kafka message for testing.
@@ -46,8 +57,8 @@ func runWorkerOnce(ctx context.Context, st store.StoreInterface, kafkaReader app
 func TestWorker_DistributePost(t *testing.T) {
 	mockStore := store.NewMock()
 
-	authorID, _ := mockStore.CreateUser("author")
-	followerID, _ := mockStore.CreateUser("follower")
+	authorID, _ := mockStore.CreateUser("author", "")
+	followerID, _ := mockStore.CreateUser("follower", "")
 	mockStore.CreateFollow(followerID, authorID)
 
 	post := models.Post{
@@ -176,3 +187,662 @@ func TestWorker_StoreGetFollowersFail(t *testing.T) {
 		t.Fatalf("expected error from store GetFollowers, got nil")
 	}
 }
+
+// ---------- Retry & dead-letter tests ----------
+
+// Store failures are retried via the retry topic, then dead-lettered
+func TestWorker_RetryThenDeadLetter(t *testing.T) {
+	retryTopic := &appkafka.MockKafka{}
+	dlqTopic := &appkafka.MockKafka{}
+
+	w := (&Worker{store: &store.MockStoreFail{}}).WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		RetryWriter: retryTopic,
+		DLQWriter:   dlqTopic,
+	})
+
+	data, _ := events.Encode(events.TypePostCreated, "test", models.Post{ID: "300", AuthorID: "author", Body: "retry me"})
+	msg := kafka.Message{Topic: "feed-topic", Value: data}
+	ctx := context.Background()
+
+	for attempt := 1; attempt < 3; attempt++ {
+		err := w.handleMessage(ctx, msg)
+		if err == nil {
+			t.Fatalf("expected processing error on attempt %d", attempt)
+		}
+		w.handleFailure(msg, err)
+
+		written := retryTopic.Written()
+		if len(written) != attempt {
+			t.Fatalf("expected %d retry messages, got %d", attempt, len(written))
+		}
+		msg = written[attempt-1]
+		if got := appkafka.RetryCount(msg); got != attempt {
+			t.Fatalf("expected retry count %d, got %d", attempt, got)
+		}
+		if appkafka.RetryNotBefore(msg).IsZero() {
+			t.Fatalf("expected retry message to carry a not-before time")
+		}
+	}
+
+	err := w.handleMessage(ctx, msg)
+	w.handleFailure(msg, err)
+
+	dead := dlqTopic.Written()
+	if len(dead) != 1 {
+		t.Fatalf("expected 1 dead-lettered message, got %d", len(dead))
+	}
+	if string(dead[0].Value) != string(data) {
+		t.Fatalf("dead-lettered payload changed: %s", dead[0].Value)
+	}
+	if appkafka.RetryCount(dead[0]) != 3 ||
+		appkafka.HeaderValue(dead[0], appkafka.HeaderOriginalTopic) != "feed-topic" ||
+		appkafka.HeaderValue(dead[0], appkafka.HeaderError) == "" {
+		t.Fatalf("unexpected dead-letter headers: %+v", dead[0].Headers)
+	}
+}
+
+// Invalid JSON cannot be fixed by retrying and goes straight to the DLQ
+func TestWorker_InvalidJSONDeadLettered(t *testing.T) {
+	retryTopic := &appkafka.MockKafka{}
+	dlqTopic := &appkafka.MockKafka{}
+
+	w := (&Worker{
+		store:  store.NewMock(),
+		reader: &MockKafkaReader{Messages: []kafka.Message{{Value: []byte("{invalid-json}")}}},
+	}).WithRetryPolicy(RetryPolicy{RetryWriter: retryTopic, DLQWriter: dlqTopic})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w.Run(ctx)
+
+	if n := len(retryTopic.Written()); n != 0 {
+		t.Fatalf("expected no retries for invalid JSON, got %d", n)
+	}
+	if n := len(dlqTopic.Written()); n != 1 {
+		t.Fatalf("expected 1 dead-lettered message, got %d", n)
+	}
+}
+
+// ---------- At-least-once delivery tests ----------
+
+// killableStore fails AddToFeeds for one post once released, simulating a
+// worker that is killed while that post's fan-out is still in flight.
+type killableStore struct {
+	*store.MockStore
+	stuckPostID string
+	release     chan struct{}
+}
+
+func (s *killableStore) AddToFeeds(userIDs []string, post models.Post) error {
+	if post.ID == s.stuckPostID {
+		<-s.release
+		return errors.New("worker killed mid fan-out")
+	}
+	return s.MockStore.AddToFeeds(userIDs, post)
+}
+
+// testPostID is the ID of the i-th test post.
+func testPostID(i int) string {
+	return fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+}
+
+// testPost is the i-th test post of authorID.
+func testPost(authorID string, i int) models.Post {
+	return models.Post{ID: testPostID(i), AuthorID: authorID, Body: "post " + strconv.Itoa(i)}
+}
+
+// addPosts stores the test posts from..to of authorID, so their creation
+// events are fanned out.
+func addPosts(st *store.MockStore, authorID string, from, to int) {
+	for i := from; i <= to; i++ {
+		st.AddPost(testPost(authorID, i))
+	}
+}
+
+// postMessages builds Kafka messages for posts with consecutive offsets on partition 0
+func postMessages(authorID string, from, to int) []kafka.Message {
+	var msgs []kafka.Message
+	for i := from; i <= to; i++ {
+		data, _ := events.Encode(events.TypePostCreated, "test", testPost(authorID, i))
+		msgs = append(msgs, kafka.Message{Topic: "feed-topic", Partition: 0, Offset: int64(i), Value: data})
+	}
+	return msgs
+}
+
+func feedHas(st *store.MockStore, userID, postID string) bool {
+	feed, _ := st.GetFeed(userID, 100)
+	for _, p := range feed {
+		if p.ID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWorker_KilledMidBatchRedeliversUncommitted(t *testing.T) {
+	mockStore := store.NewMock()
+	authorID, _ := mockStore.CreateUser("author", "")
+	followerID, _ := mockStore.CreateUser("follower", "")
+	mockStore.CreateFollow(followerID, authorID)
+	addPosts(mockStore, authorID, 0, 4)
+
+	// --- First run: post 2 never finishes, posts 0,1,3,4 do ---
+	st := &killableStore{MockStore: mockStore, stuckPostID: testPostID(2), release: make(chan struct{})}
+	reader := &MockKafkaReader{Messages: postMessages(authorID, 0, 4)}
+	w := New(st, reader, 4, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for !(feedHas(mockStore, followerID, testPostID(3)) && feedHas(mockStore, followerID, testPostID(4))) {
+		if time.Now().After(deadline) {
+			t.Fatal("posts after the stuck one were not processed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Kill the worker while post 2 is still being fanned out
+	cancel()
+	close(st.release)
+	<-done
+
+	if got := reader.LastCommitted(); got != 1 {
+		t.Fatalf("expected offsets committed only up to 1, got %d", got)
+	}
+	if feedHas(mockStore, followerID, testPostID(2)) {
+		t.Fatal("post 2 should not have been delivered in the first run")
+	}
+
+	// --- Restart: the group resumes after the last committed offset ---
+	reader = &MockKafkaReader{Messages: postMessages(authorID, 2, 4)}
+	w = New(mockStore, reader, 4, 10)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	w.Run(ctx)
+
+	if !feedHas(mockStore, followerID, testPostID(2)) {
+		t.Fatal("expected post 2 to be delivered after restart")
+	}
+	if got := reader.LastCommitted(); got != 4 {
+		t.Fatalf("expected offsets committed up to 4 after restart, got %d", got)
+	}
+}
+
+func TestOffsetTracker_CommitsContiguousPrefix(t *testing.T) {
+	tr := newOffsetTracker()
+	msgs := postMessages("a", 0, 3)
+	for _, m := range msgs {
+		tr.track(m)
+	}
+
+	if _, ok := tr.complete(msgs[2]); ok {
+		t.Fatal("offset 2 must not be committable before 0 and 1")
+	}
+	if _, ok := tr.complete(msgs[1]); ok {
+		t.Fatal("offset 1 must not be committable before 0")
+	}
+	commit, ok := tr.complete(msgs[0])
+	if !ok || commit.Offset != 2 {
+		t.Fatalf("expected commit up to offset 2, got %d (ok=%v)", commit.Offset, ok)
+	}
+	commit, ok = tr.complete(msgs[3])
+	if !ok || commit.Offset != 3 {
+		t.Fatalf("expected commit up to offset 3, got %d (ok=%v)", commit.Offset, ok)
+	}
+}
+
+// a partition fetched again from an earlier offset starts a new generation,
+// whose commits are not blocked by messages of the old one
+func TestOffsetTracker_ResetsOnNewGeneration(t *testing.T) {
+	tr := newOffsetTracker()
+	msgs := postMessages("a", 0, 3)
+	for _, m := range msgs {
+		tr.track(m)
+	}
+	tr.complete(msgs[1])
+
+	// Rebalance: the partition is resumed from the committed offset 0
+	tr.track(msgs[0])
+	tr.track(msgs[1])
+	if _, ok := tr.complete(msgs[3]); ok {
+		t.Fatal("offsets of the old generation must be ignored")
+	}
+	tr.complete(msgs[0])
+	commit, ok := tr.complete(msgs[1])
+	if !ok || commit.Offset != 1 {
+		t.Fatalf("expected commit up to offset 1, got %d (ok=%v)", commit.Offset, ok)
+	}
+}
+
+// flakyWriter fails the first fails writes.
+type flakyWriter struct {
+	appkafka.MockKafka
+	mu    sync.Mutex
+	fails int
+}
+
+func (w *flakyWriter) WriteMessages(msgs ...kafka.Message) error {
+	w.mu.Lock()
+	if w.fails > 0 {
+		w.fails--
+		w.mu.Unlock()
+		return errors.New("broker unavailable")
+	}
+	w.mu.Unlock()
+	return w.MockKafka.WriteMessages(msgs...)
+}
+
+// a failed hand-off to the dead-letter topic is repeated, so the offset is
+// committed eventually instead of blocking the partition
+func TestWorker_HandOffRetriedUntilAccepted(t *testing.T) {
+	dlq := &flakyWriter{fails: 2}
+	reader := &MockKafkaReader{Messages: []kafka.Message{
+		{Topic: "feed-topic", Offset: 0, Value: []byte("{invalid-json}")},
+	}}
+	w := (&Worker{store: store.NewMock(), reader: reader}).
+		WithRetryPolicy(RetryPolicy{DLQWriter: dlq})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go w.Run(ctx)
+
+	for reader.LastCommitted() != 0 {
+		if ctx.Err() != nil {
+			t.Fatal("offset of the dead-lettered message was never committed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := len(dlq.Written()); n != 1 {
+		t.Fatalf("expected 1 dead-lettered message, got %d", n)
+	}
+}
+
+// ---------- Unfollow tests ----------
+
+func TestWorker_FollowDeletedPurgesFeed(t *testing.T) {
+	mockStore := store.NewMock()
+	mockStore.AddToFeed("follower", models.Post{ID: "1", AuthorID: "gone"})
+	mockStore.AddToFeed("follower", models.Post{ID: "2", AuthorID: "kept"})
+
+	data, _ := events.Encode(events.TypeFollowDeleted, "test", models.Follow{UserID: "follower", FolloweeID: "gone"})
+	msg := kafka.Message{Key: []byte(events.TypeFollowDeleted), Value: data}
+
+	w := &Worker{store: mockStore}
+	if err := w.handleMessage(context.Background(), msg); err != nil {
+		t.Fatalf("handleMessage failed: %v", err)
+	}
+
+	feed, _ := mockStore.GetFeed("follower", 10)
+	if len(feed) != 1 || feed[0].AuthorID != "kept" {
+		t.Fatalf("expected only the kept author's post, got %+v", feed)
+	}
+}
+
+// ---------- Post edit & delete tests ----------
+
+func TestWorker_PostUpdatedAndDeleted(t *testing.T) {
+	mockStore := store.NewMock()
+	mockStore.CreateFollow("follower", "author")
+
+	post := models.Post{ID: "500", AuthorID: "author", Body: "original", Created: time.Now()}
+	mockStore.AddToFeed("follower", post)
+
+	w := &Worker{store: mockStore}
+	ctx := context.Background()
+
+	post.Body = "edited"
+	data, _ := events.Encode(events.TypePostUpdated, "test", post)
+	if err := w.handleMessage(ctx, kafka.Message{Key: []byte(events.TypePostUpdated), Value: data}); err != nil {
+		t.Fatalf("post_updated failed: %v", err)
+	}
+	feed, _ := mockStore.GetFeed("follower", 10)
+	if len(feed) != 1 || feed[0].Body != "edited" {
+		t.Fatalf("expected edited post in feed, got %+v", feed)
+	}
+
+	data, _ = events.Encode(events.TypePostDeleted, "test", post)
+	if err := w.handleMessage(ctx, kafka.Message{Key: []byte(events.TypePostDeleted), Value: data}); err != nil {
+		t.Fatalf("post_deleted failed: %v", err)
+	}
+	feed, _ = mockStore.GetFeed("follower", 10)
+	if len(feed) != 0 {
+		t.Fatalf("expected post removed from feed, got %+v", feed)
+	}
+}
+
+// an update only rewrites feeds that hold the post: it neither reaches a
+// later follower nor brings the post back after an overtaking deletion
+func TestWorker_PostUpdatedOnlyRewritesExistingRows(t *testing.T) {
+	mockStore := store.NewMock()
+	mockStore.CreateFollow("follower", "author")
+	mockStore.CreateFollow("late-follower", "author")
+
+	post := models.Post{ID: "510", AuthorID: "author", Body: "original", Created: time.Now()}
+	mockStore.AddToFeed("follower", post)
+
+	w := &Worker{store: mockStore}
+	ctx := context.Background()
+
+	post.Body = "edited"
+	updated, _ := events.Encode(events.TypePostUpdated, "test", post)
+	if err := w.handleMessage(ctx, kafka.Message{Value: updated}); err != nil {
+		t.Fatalf("post_updated failed: %v", err)
+	}
+	if feed, _ := mockStore.GetFeed("late-follower", 10); len(feed) != 0 {
+		t.Fatalf("expected no post in a later follower's feed, got %+v", feed)
+	}
+
+	deleted, _ := events.Encode(events.TypePostDeleted, "test", post)
+	if err := w.handleMessage(ctx, kafka.Message{Value: deleted}); err != nil {
+		t.Fatalf("post_deleted failed: %v", err)
+	}
+	// The update is redelivered from the retry topic after the deletion
+	if err := w.handleMessage(ctx, kafka.Message{Value: updated}); err != nil {
+		t.Fatalf("late post_updated failed: %v", err)
+	}
+	if feed, _ := mockStore.GetFeed("follower", 10); len(feed) != 0 {
+		t.Fatalf("expected deleted post to stay deleted, got %+v", feed)
+	}
+}
+
+// ---------- Event envelope tests ----------
+
+func TestWorker_UnsupportedEventsDeadLettered(t *testing.T) {
+	unknownType, _ := json.Marshal(events.Envelope{Type: "post_liked", SchemaVersion: 1, Payload: []byte(`{}`)})
+	futureVersion, _ := json.Marshal(events.Envelope{Type: events.TypePostCreated, SchemaVersion: 99, Payload: []byte(`{}`)})
+	legacyPost, _ := json.Marshal(models.Post{ID: "1", AuthorID: "author"})
+
+	for name, data := range map[string][]byte{
+		"unknown type":     unknownType,
+		"unknown version":  futureVersion,
+		"missing envelope": legacyPost,
+	} {
+		t.Run(name, func(t *testing.T) {
+			retryTopic := &appkafka.MockKafka{}
+			dlqTopic := &appkafka.MockKafka{}
+			w := (&Worker{store: store.NewMock()}).WithRetryPolicy(RetryPolicy{RetryWriter: retryTopic, DLQWriter: dlqTopic})
+
+			msg := kafka.Message{Value: data}
+			err := w.handleMessage(context.Background(), msg)
+			if err == nil {
+				t.Fatal("expected the event to be rejected")
+			}
+			w.handleFailure(msg, err)
+
+			if len(retryTopic.Written()) != 0 || len(dlqTopic.Written()) != 1 {
+				t.Fatalf("expected straight to DLQ, got retries=%d dlq=%d", len(retryTopic.Written()), len(dlqTopic.Written()))
+			}
+		})
+	}
+}
+
+// ---------- Hybrid feed tests ----------
+
+func TestWorker_CelebrityPostMergedOnRead(t *testing.T) {
+	mockStore := store.NewMock()
+	mockStore.CelebrityThreshold = 2
+	mockStore.CreateFollow("fan1", "star")
+	mockStore.CreateFollow("fan2", "star")
+	mockStore.CreateFollow("fan1", "friend")
+
+	old := models.Post{ID: testPostID(1), AuthorID: "friend", Body: "older", Created: time.Now().Add(-time.Minute)}
+	mockStore.AddToFeed("fan1", old)
+
+	post := models.Post{ID: testPostID(2), AuthorID: "star", Body: "for millions", Created: time.Now()}
+	mockStore.AddPost(post)
+
+	w := &Worker{store: mockStore}
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
+	if err := w.handleMessage(context.Background(), kafka.Message{Value: data}); err != nil {
+		t.Fatalf("post_created failed: %v", err)
+	}
+
+	if len(mockStore.Feed["fan1"]) != 1 || len(mockStore.Feed["fan2"]) != 0 {
+		t.Fatalf("expected no fan-out for celebrity post, got %+v", mockStore.Feed)
+	}
+
+	feed, _ := mockStore.GetFeed("fan1", 10)
+	if len(feed) != 2 || feed[0].ID != post.ID || feed[1].ID != old.ID {
+		t.Fatalf("expected celebrity post merged before older post, got %+v", feed)
+	}
+}
+
+// a creation event that arrives after the post was deleted is not fanned out
+func TestWorker_DeletedPostNotFannedOut(t *testing.T) {
+	mockStore := store.NewMock()
+	mockStore.CreateFollow("follower", "author")
+
+	w := &Worker{store: mockStore}
+	data, _ := events.Encode(events.TypePostCreated, "test", testPost("author", 1))
+	if err := w.handleMessage(context.Background(), kafka.Message{Value: data}); err != nil {
+		t.Fatalf("post_created failed: %v", err)
+	}
+	if feedHas(mockStore, "follower", testPostID(1)) {
+		t.Fatal("deleted post was fanned out")
+	}
+}
+
+// ---------- Batched fan-out tests ----------
+
+// batchRecordingStore records the size of every AddToFeeds batch.
+type batchRecordingStore struct {
+	*store.MockStore
+	mu      sync.Mutex
+	batches []int
+}
+
+func (s *batchRecordingStore) AddToFeeds(userIDs []string, post models.Post) error {
+	s.mu.Lock()
+	s.batches = append(s.batches, len(userIDs))
+	s.mu.Unlock()
+	return s.MockStore.AddToFeeds(userIDs, post)
+}
+
+func TestWorker_FanOutInBatches(t *testing.T) {
+	st := &batchRecordingStore{MockStore: store.NewMock()}
+	for i := 0; i < 7; i++ {
+		st.CreateFollow("follower"+strconv.Itoa(i), "author")
+	}
+
+	post := testPost("author", 1)
+	st.AddPost(post)
+
+	w := (&Worker{store: st}).WithFanoutPolicy(FanoutPolicy{Concurrency: 2, BatchSize: 3})
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
+	if err := w.handleMessage(context.Background(), kafka.Message{Value: data}); err != nil {
+		t.Fatalf("post_created failed: %v", err)
+	}
+
+	sort.Ints(st.batches)
+	if len(st.batches) != 3 || st.batches[0] != 1 || st.batches[2] != 3 {
+		t.Fatalf("expected batches of 3, 3 and 1, got %v", st.batches)
+	}
+	for i := 0; i < 7; i++ {
+		if !feedHas(st.MockStore, "follower"+strconv.Itoa(i), post.ID) {
+			t.Fatalf("post missing from follower%d's feed", i)
+		}
+	}
+}
+
+// ---------- Processed events ledger tests ----------
+
+// countingStore counts AddToFeeds calls.
+type countingStore struct {
+	*store.MockStore
+	mu    sync.Mutex
+	calls int
+}
+
+func (s *countingStore) AddToFeeds(userIDs []string, post models.Post) error {
+	s.mu.Lock()
+	s.calls++
+	s.mu.Unlock()
+	return s.MockStore.AddToFeeds(userIDs, post)
+}
+
+func TestWorker_RedeliveredEventSkipped(t *testing.T) {
+	st := &countingStore{MockStore: store.NewMock()}
+	st.CreateFollow("follower", "author")
+	post := testPost("author", 1)
+	st.AddPost(post)
+
+	w := (&Worker{store: st}).WithLedger(NewLedger(st, time.Hour, 10))
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
+	msg := kafka.Message{Value: data}
+
+	for i := 0; i < 3; i++ {
+		if err := w.handleMessage(context.Background(), msg); err != nil {
+			t.Fatalf("delivery %d failed: %v", i, err)
+		}
+	}
+	if st.calls != 1 {
+		t.Fatalf("expected one fan-out for a redelivered event, got %d", st.calls)
+	}
+
+	// A fresh ledger (e.g. after a restart) still finds the event in the store
+	w = (&Worker{store: st}).WithLedger(NewLedger(st, time.Hour, 10))
+	if err := w.handleMessage(context.Background(), msg); err != nil {
+		t.Fatalf("redelivery after restart failed: %v", err)
+	}
+	if st.calls != 1 {
+		t.Fatalf("expected the store ledger to skip the event, got %d fan-outs", st.calls)
+	}
+}
+
+func TestLedger_EvictsLeastRecentlyUsed(t *testing.T) {
+	l := NewLedger(store.NewMock(), time.Hour, 2)
+	for _, id := range []string{"a", "b"} {
+		l.MarkProcessed(id)
+	}
+	l.cached("a")
+	l.MarkProcessed("c")
+
+	if l.cached("b") {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if !l.cached("a") || !l.cached("c") {
+		t.Fatal("expected recently used entries to stay cached")
+	}
+}
+
+// ---------- Tracing test ----------
+
+// the worker's spans continue the trace carried in the message headers
+func TestWorker_TraceContinuesFromHeaders(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+	tracing.Init(context.Background(), "", 1, "test")
+
+	mockStore := store.NewMock()
+	authorID, _ := mockStore.CreateUser("author", "")
+	followerID, _ := mockStore.CreateUser("follower", "")
+	mockStore.CreateFollow(followerID, authorID)
+
+	post := models.Post{ID: testPostID(1), AuthorID: authorID, Body: "traced", Created: time.Now()}
+	mockStore.AddPost(post)
+	data, _ := events.Encode(events.TypePostCreated, "test", post)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	msg := kafka.Message{
+		Topic:   "feed-topic",
+		Key:     []byte(events.TypePostCreated),
+		Value:   data,
+		Headers: []kafka.Header{{Key: "traceparent", Value: []byte("00-" + traceID + "-" + parentID + "-01")}},
+	}
+
+	w := &Worker{store: mockStore}
+	if err := w.process(context.Background(), msg); err != nil {
+		t.Fatalf("process failed: %v", err)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID().String() != traceID {
+			t.Fatalf("span %q is not part of trace %s", span.Name(), traceID)
+		}
+		spans[span.Name()] = span
+	}
+	process, fanout := spans["process "+events.TypePostCreated], spans["fanout"]
+	if process == nil || fanout == nil {
+		t.Fatalf("expected process and fanout spans, got %v", spans)
+	}
+	if process.Parent().SpanID().String() != parentID {
+		t.Fatalf("expected process span to continue %s, got parent %s", parentID, process.Parent().SpanID())
+	}
+	if fanout.Parent().SpanID() != process.SpanContext().SpanID() {
+		t.Fatalf("expected fanout span to be a child of the process span")
+	}
+}
+
+// the worker is ready while idle but not before it runs or while fetches fail
+func TestWorker_CheckProgress(t *testing.T) {
+	idle := New(store.NewMock(), &MockKafkaReader{}, 1, 1).WithStallTimeout(100 * time.Millisecond)
+	if err := idle.CheckProgress(context.Background()); err == nil {
+		t.Fatal("expected progress check to fail before the worker runs")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go idle.Run(ctx)
+
+	failing := New(store.NewMock(), &appkafka.MockKafkaFail{}, 1, 1).WithStallTimeout(100 * time.Millisecond)
+	go failing.Run(ctx)
+
+	time.Sleep(300 * time.Millisecond)
+	if err := idle.CheckProgress(ctx); err != nil {
+		t.Fatalf("expected idle worker to be ready, got %v", err)
+	}
+	if err := failing.CheckProgress(ctx); err == nil {
+		t.Fatal("expected worker failing to fetch to be not ready")
+	}
+}
+
+// lateReader returns its message only once the fetch deadline has passed
+type lateReader struct {
+	MockKafkaReader
+	once sync.Once
+	msg  kafka.Message
+}
+
+func (r *lateReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	var msg kafka.Message
+	r.once.Do(func() { msg = r.msg })
+	if msg.Value == nil {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	<-ctx.Done()
+	return msg, nil
+}
+
+// a message fetched just as the idle deadline passes is processed, not dropped
+func TestWorker_MessageAtFetchDeadlineProcessed(t *testing.T) {
+	mockStore := store.NewMock()
+	authorID, _ := mockStore.CreateUser("author", "")
+	followerID, _ := mockStore.CreateUser("follower", "")
+	mockStore.CreateFollow(followerID, authorID)
+	addPosts(mockStore, authorID, 0, 0)
+
+	reader := &lateReader{msg: postMessages(authorID, 0, 0)[0]}
+	w := New(mockStore, reader, 1, 1).WithStallTimeout(40 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	w.Run(ctx)
+
+	if !feedHas(mockStore, followerID, testPostID(0)) {
+		t.Fatal("message returned at the fetch deadline was dropped")
+	}
+	if got := reader.LastCommitted(); got != 0 {
+		t.Fatalf("expected offset 0 committed, got %d", got)
+	}
+}
@@ -0,0 +1,183 @@
+package apierr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"example.com/cassandrafeed/internal/requestid"
+	"github.com/gocql/gocql"
+	"github.com/segmentio/kafka-go"
+)
+
+// Code identifies the kind of failure independently of the message text, so
+// clients can branch on it.
+type Code string
+
+const (
+	CodeInvalidRequest   Code = "invalid_request"
+	CodeUnauthorized     Code = "unauthorized"
+	CodeForbidden        Code = "forbidden"
+	CodeNotFound         Code = "not_found"
+	CodeMethodNotAllowed Code = "method_not_allowed"
+	CodeConflict         Code = "conflict"
+	CodeTooLarge         Code = "too_large"
+	CodeUnprocessable    Code = "unprocessable"
+	CodeRateLimited      Code = "rate_limited"
+	CodeUnavailable      Code = "unavailable"
+	CodeInternal         Code = "internal"
+)
+
+// Error is an API failure with its HTTP status. Err holds the underlying
+// cause for logs and is never sent to clients.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details map[string]any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// WithDetails attaches machine-readable context, e.g. the offending field.
+func (e *Error) WithDetails(details map[string]any) *Error {
+	e.Details = details
+	return e
+}
+
+func BadRequest(msg string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Message: msg}
+}
+
+func Unauthorized(msg string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: msg}
+}
+
+func Forbidden(msg string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Message: msg}
+}
+
+func NotFound(msg string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: msg}
+}
+
+func MethodNotAllowed(msg string) *Error {
+	return &Error{Status: http.StatusMethodNotAllowed, Code: CodeMethodNotAllowed, Message: msg}
+}
+
+func Conflict(msg string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: msg}
+}
+
+func TooLarge(msg string) *Error {
+	return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeTooLarge, Message: msg}
+}
+
+func Unprocessable(msg string) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeUnprocessable, Message: msg}
+}
+
+func TooManyRequests(msg string) *Error {
+	return &Error{Status: http.StatusTooManyRequests, Code: CodeRateLimited, Message: msg}
+}
+
+// Internal hides err behind a generic message.
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "internal error", Err: err}
+}
+
+// mapping turns errors matching target into an API error.
+type mapping struct {
+	target error
+	status int
+	code   Code
+	msg    string
+}
+
+var (
+	mappingsMu sync.RWMutex
+	mappings   []mapping
+)
+
+// Register makes From map errors matching target to an API error with
+// status, code and msg. It lets the packages that define sentinel errors stay
+// out of this one, which every layer imports.
+func Register(target error, status int, code Code, msg string) {
+	mappingsMu.Lock()
+	defer mappingsMu.Unlock()
+	mappings = append(mappings, mapping{target: target, status: status, code: code, msg: msg})
+}
+
+// From maps an error returned by the store or broker to an API error, using
+// the mappings added with Register. Unknown errors become a generic internal
+// error.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	mappingsMu.RLock()
+	for _, m := range mappings {
+		if errors.Is(err, m.target) {
+			mappingsMu.RUnlock()
+			return &Error{Status: m.status, Code: m.code, Message: m.msg, Err: err}
+		}
+	}
+	mappingsMu.RUnlock()
+
+	switch {
+	case errors.Is(err, gocql.ErrNotFound):
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: "not found", Err: err}
+	case isUnavailable(err):
+		return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: "service temporarily unavailable", Err: err}
+	}
+	return Internal(err)
+}
+
+// isUnavailable reports whether err is a transient Cassandra or Kafka failure
+// that a client may retry.
+func isUnavailable(err error) bool {
+	var unavailable *gocql.RequestErrUnavailable
+	var writeTimeout *gocql.RequestErrWriteTimeout
+	var readTimeout *gocql.RequestErrReadTimeout
+	var kafkaErr kafka.Error
+	return errors.Is(err, gocql.ErrNoConnections) ||
+		errors.Is(err, gocql.ErrTimeoutNoResponse) ||
+		errors.As(err, &unavailable) ||
+		errors.As(err, &writeTimeout) ||
+		errors.As(err, &readTimeout) ||
+		(errors.As(err, &kafkaErr) && kafkaErr.Temporary())
+}
+
+// response is the JSON body of every error response.
+type response struct {
+	Code      Code           `json:"code"`
+	Message   string         `json:"message"`
+	RequestID string         `json:"request_id,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// Write sends err as a JSON error response tagged with the request ID.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := From(err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(response{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		RequestID: requestid.FromContext(r.Context()),
+		Details:   apiErr.Details,
+	})
+}
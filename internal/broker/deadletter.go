@@ -0,0 +1,109 @@
+package appkafka
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Header keys attached to messages routed through the retry and dead-letter topics.
+const (
+	HeaderRetryCount     = "x-retry-count"      // number of failed processing attempts so far
+	HeaderRetryNotBefore = "x-retry-not-before" // RFC3339Nano time before which a retry must not run
+	HeaderError          = "x-error"            // last processing error
+	HeaderOriginalTopic  = "x-original-topic"   // topic the message was first consumed from
+	HeaderFailedAt       = "x-failed-at"        // RFC3339Nano time of the last failure
+)
+
+// failureHeaders lists every header added by the retry pipeline.
+var failureHeaders = []string{
+	HeaderRetryCount,
+	HeaderRetryNotBefore,
+	HeaderError,
+	HeaderOriginalTopic,
+	HeaderFailedAt,
+}
+
+// HeaderValue returns the value of the first header with the given key, or "".
+func HeaderValue(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// RetryCount returns how many times the message has already failed processing.
+func RetryCount(msg kafka.Message) int {
+	n, err := strconv.Atoi(HeaderValue(msg, HeaderRetryCount))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// RetryNotBefore returns the earliest time a retried message may be processed.
+// The zero time is returned for messages that were never scheduled for retry.
+func RetryNotBefore(msg kafka.Message) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, HeaderValue(msg, HeaderRetryNotBefore))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// NewFailureMessage copies the original key, payload and headers of msg into a
+// fresh message for the retry or dead-letter topic and records the failure.
+func NewFailureMessage(msg kafka.Message, attempts int, notBefore time.Time, cause error) kafka.Message {
+	origin := HeaderValue(msg, HeaderOriginalTopic)
+	if origin == "" {
+		origin = msg.Topic
+	}
+
+	out := kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: withoutHeaders(msg.Headers, failureHeaders...),
+	}
+	out.Headers = append(out.Headers,
+		kafka.Header{Key: HeaderRetryCount, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(origin)},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+	if cause != nil {
+		out.Headers = append(out.Headers, kafka.Header{Key: HeaderError, Value: []byte(cause.Error())})
+	}
+	if !notBefore.IsZero() {
+		out.Headers = append(out.Headers, kafka.Header{Key: HeaderRetryNotBefore, Value: []byte(notBefore.UTC().Format(time.RFC3339Nano))})
+	}
+	return out
+}
+
+// NewReplayMessage strips the failure metadata from a dead-lettered message so
+// it can be re-injected into the main topic as if it were new.
+func NewReplayMessage(msg kafka.Message) kafka.Message {
+	return kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: withoutHeaders(msg.Headers, failureHeaders...),
+	}
+}
+
+func withoutHeaders(headers []kafka.Header, keys ...string) []kafka.Header {
+	var res []kafka.Header
+	for _, h := range headers {
+		drop := false
+		for _, k := range keys {
+			if h.Key == k {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			res = append(res, h)
+		}
+	}
+	return res
+}
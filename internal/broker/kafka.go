@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"example.com/cassandrafeed/internal/metrics"
 	"github.com/segmentio/kafka-go"
 )
 
@@ -15,8 +16,12 @@ type KafkaWriter interface {
 }
 
 // KafkaReader defines an interface for reading messages from Kafka.
+// ReadMessage commits the offset as soon as the message is read, while
+// FetchMessage leaves committing to an explicit CommitMessages call.
 type KafkaReader interface {
 	ReadMessage(ctx context.Context) (kafka.Message, error)
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
 	Close() error
 }
 
@@ -60,8 +65,10 @@ func (w *RealKafkaWriter) WriteMessages(messages ...kafka.Message) error {
 	if w.conn == nil {
 		return errors.New("kafka connection is nil")
 	}
-	w.conn.SetWriteDeadline(time.Now().Add(w.config.WriteTimeout))
+	start := time.Now()
+	w.conn.SetWriteDeadline(start.Add(w.config.WriteTimeout))
 	_, err := w.conn.WriteMessages(messages...)
+	metrics.ObserveKafkaWrite(w.config.Topic, time.Since(start), err)
 	return err
 }
 
@@ -72,6 +79,27 @@ func (w *RealKafkaWriter) Close() error {
 	return nil
 }
 
+// Ping checks that one of brokers accepts connections and answers a
+// metadata request.
+func Ping(ctx context.Context, brokers []string) error {
+	err := errors.New("no Kafka brokers configured")
+	for _, addr := range brokers {
+		var conn *kafka.Conn
+		if conn, err = kafka.DialContext(ctx, "tcp", addr); err != nil {
+			continue
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		_, err = conn.Brokers()
+		conn.Close()
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
 // RealKafkaReader implements KafkaReader using kafka.Reader (consumer group).
 type RealKafkaReader struct {
 	reader *kafka.Reader
@@ -89,7 +117,7 @@ func NewKafkaReader(cfg KafkaConfig) KafkaReader {
 		Topic:          cfg.Topic,
 		MinBytes:       10e3, // 10KB
 		MaxBytes:       10e6, // 10MB
-		CommitInterval: time.Second,
+		CommitInterval: 0,    // commit synchronously, only when the consumer asks to
 	})
 	return &RealKafkaReader{reader: r}
 }
@@ -98,6 +126,14 @@ func (r *RealKafkaReader) ReadMessage(ctx context.Context) (kafka.Message, error
 	return r.reader.ReadMessage(ctx)
 }
 
+func (r *RealKafkaReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return r.reader.FetchMessage(ctx)
+}
+
+func (r *RealKafkaReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return r.reader.CommitMessages(ctx, msgs...)
+}
+
 func (r *RealKafkaReader) Close() error {
 	return r.reader.Close()
 }
@@ -4,43 +4,78 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sync"
 
+	"example.com/cassandrafeed/internal/events"
 	"example.com/cassandrafeed/internal/models"
 	"example.com/cassandrafeed/internal/store"
 	"github.com/segmentio/kafka-go"
 )
 
-// MockKafka immediately applies posts to the store for followers.
+// MockKafka records written messages and, when Store is set, immediately
+// applies posts to the store for followers.
 type MockKafka struct {
+	mu              sync.Mutex
 	Store           *store.MockStore
 	WrittenMessages []kafka.Message // stores messages written via WriteMessages
-	ReadMessages    []kafka.Message // queue of messages to be read via ReadMessage
+	ReadMessages    []kafka.Message // queue of messages to be read via ReadMessage or FetchMessage
+	Committed       []kafka.Message // messages committed via CommitMessages
 	ShouldFail      bool            // flag to simulate failures during write or read operations
 }
 
-// WriteMessages simulates writing a post to Kafka, immediately adding it to followers' feeds.
+// WriteMessages simulates writing events to Kafka, immediately applying them to followers' feeds.
 func (m *MockKafka) WriteMessages(messages ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock kafka write failed")
+	}
+
+	m.WrittenMessages = append(m.WrittenMessages, messages...)
 	if m.Store == nil {
-		return errors.New("store is nil")
+		// Without a store the mock only records messages (e.g. retry/DLQ topics)
+		return nil
 	}
 
 	for _, msg := range messages {
-		var post models.Post
-		if err := json.Unmarshal(msg.Value, &post); err != nil {
+		env, err := events.Decode(msg.Value)
+		if err != nil {
 			return err
 		}
 
-		// Add post to author's own feed
-		_ = m.Store.AddToFeed(post.AuthorID, post)
+		if env.Type == events.TypeFollowDeleted {
+			var follow models.Follow
+			if err := json.Unmarshal(env.Payload, &follow); err != nil {
+				return err
+			}
+			_ = m.Store.RemoveAuthorFromFeed(follow.UserID, follow.FolloweeID)
+			continue
+		}
 
-		// Add post to followers' feeds
-		followers, _ := m.Store.GetFollowers(post.AuthorID)
-		for _, followerID := range followers {
-			_ = m.Store.AddToFeed(followerID, post)
+		var post models.Post
+		if err := json.Unmarshal(env.Payload, &post); err != nil {
+			return err
+		}
+
+		// Apply the event to the author's own feed and, like the worker,
+		// to followers' feeds unless the author is a celebrity
+		targets := []string{post.AuthorID}
+		if celebrity, _ := m.Store.IsCelebrity(post.AuthorID); !celebrity {
+			followers, _ := m.Store.GetFollowers(post.AuthorID)
+			targets = append(targets, followers...)
+		}
+		for _, userID := range targets {
+			if env.Type == events.TypePostDeleted {
+				_ = m.Store.RemoveFromFeed(userID, post)
+			} else {
+				_ = m.Store.AddToFeed(userID, post)
+			}
 		}
 
 		// Store posts in Posts map
-		_ = m.Store.AddPost(post)
+		if env.Type != events.TypePostDeleted {
+			_ = m.Store.AddPost(post)
+		}
 	}
 
 	return nil
@@ -48,6 +83,8 @@ func (m *MockKafka) WriteMessages(messages ...kafka.Message) error {
 
 // ReadMessage is a no-op in tests.
 func (m *MockKafka) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return kafka.Message{}, errors.New("mock kafka read failed")
 	}
@@ -60,6 +97,36 @@ func (m *MockKafka) ReadMessage(ctx context.Context) (kafka.Message, error) {
 	return msg, nil
 }
 
+// FetchMessage returns the next queued message without committing it.
+func (m *MockKafka) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return m.ReadMessage(ctx)
+}
+
+// CommitMessages records committed messages.
+func (m *MockKafka) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock kafka commit failed")
+	}
+	m.Committed = append(m.Committed, msgs...)
+	return nil
+}
+
+// CommittedMessages returns a copy of the messages committed so far.
+func (m *MockKafka) CommittedMessages() []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message(nil), m.Committed...)
+}
+
+// Written returns a copy of the messages written so far.
+func (m *MockKafka) Written() []kafka.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Message(nil), m.WrittenMessages...)
+}
+
 // Close is a no-op.
 func (m *MockKafka) Close() error { return nil }
 
@@ -74,4 +141,12 @@ func (m *MockKafkaFail) ReadMessage(ctx context.Context) (kafka.Message, error)
 	return kafka.Message{}, errors.New("mock kafka read failed")
 }
 
+func (m *MockKafkaFail) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return kafka.Message{}, errors.New("mock kafka fetch failed")
+}
+
+func (m *MockKafkaFail) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return errors.New("mock kafka commit failed")
+}
+
 func (m *MockKafkaFail) Close() error { return nil }
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types carried on the feed topic. Post events are keyed by post ID and
+// follow events by follower ID, so the events of one post or follower share a
+// partition and are consumed in order.
+const (
+	TypePostCreated   = "post_created"
+	TypePostUpdated   = "post_updated"
+	TypePostDeleted   = "post_deleted"
+	TypeFollowDeleted = "follow_deleted"
+)
+
+// CurrentVersion is the schema version producers emit for every event type.
+const CurrentVersion = 1
+
+var (
+	// ErrMalformed is returned for messages that are not a valid envelope.
+	ErrMalformed = errors.New("malformed event envelope")
+	// ErrUnknownType is returned for event types without a registered decoder.
+	ErrUnknownType = errors.New("unknown event type")
+	// ErrUnsupportedVersion is returned for known types with an unknown schema version.
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+)
+
+// Envelope wraps every event published to Kafka.
+type Envelope struct {
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	ID            string          `json:"event_id"`
+	Producer      string          `json:"producer"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// New wraps payload into an envelope of the current schema version.
+func New(eventType, producer string, payload any) (Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("marshal %s payload: %w", eventType, err)
+	}
+	return Envelope{
+		Type:          eventType,
+		SchemaVersion: CurrentVersion,
+		ID:            uuid.NewString(),
+		Producer:      producer,
+		Timestamp:     time.Now().UTC(),
+		Payload:       data,
+	}, nil
+}
+
+// Encode builds an envelope for payload and returns its JSON encoding.
+func Encode(eventType, producer string, payload any) ([]byte, error) {
+	env, err := New(eventType, producer, payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Decode parses an envelope without interpreting its payload.
+func Decode(data []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Envelope{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if env.Type == "" || env.SchemaVersion <= 0 || len(env.Payload) == 0 {
+		return Envelope{}, fmt.Errorf("%w: missing type, schema_version or payload", ErrMalformed)
+	}
+	return env, nil
+}
+
+// IsRejected reports whether err means the event can never be processed,
+// no matter how often it is retried.
+func IsRejected(err error) bool {
+	return errors.Is(err, ErrMalformed) ||
+		errors.Is(err, ErrUnknownType) ||
+		errors.Is(err, ErrUnsupportedVersion)
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+)
+
+// testRegistry registers the payload types the worker consumes and records
+// the payload each handler receives.
+func testRegistry(got *any) *Registry {
+	r := NewRegistry()
+	post := func(ctx context.Context, env Envelope, p models.Post) error { *got = p; return nil }
+	follow := func(ctx context.Context, env Envelope, f models.Follow) error { *got = f; return nil }
+	Handle(r, TypePostCreated, CurrentVersion, post)
+	Handle(r, TypePostUpdated, CurrentVersion, post)
+	Handle(r, TypePostDeleted, CurrentVersion, post)
+	Handle(r, TypeFollowDeleted, CurrentVersion, follow)
+	return r
+}
+
+func TestRegistry_RoundTrip(t *testing.T) {
+	post := models.Post{
+		ID:       "9b2c1c52-5e0e-4a39-9a57-2b0d7b3f1a10",
+		AuthorID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		Body:     "hello",
+		Created:  time.UnixMilli(1767225600123).UTC(),
+	}
+	follow := models.Follow{
+		UserID:     "6ba7b811-9dad-11d1-80b4-00c04fd430c8",
+		FolloweeID: "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	}
+	tests := []struct {
+		eventType string
+		payload   any
+	}{
+		{TypePostCreated, post},
+		{TypePostUpdated, post},
+		{TypePostDeleted, post},
+		{TypeFollowDeleted, follow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.eventType, func(t *testing.T) {
+			data, err := Encode(tt.eventType, "test", tt.payload)
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			env, err := Decode(data)
+			if err != nil {
+				t.Fatalf("Decode failed: %v", err)
+			}
+			if env.Type != tt.eventType || env.SchemaVersion != CurrentVersion || env.Producer != "test" || env.ID == "" {
+				t.Errorf("envelope = %+v", env)
+			}
+
+			var got any
+			if err := testRegistry(&got).Dispatch(context.Background(), data); err != nil {
+				t.Fatalf("Dispatch failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.payload) {
+				t.Errorf("handler got %+v, want %+v", got, tt.payload)
+			}
+		})
+	}
+}
+
+func TestRegistry_Rejects(t *testing.T) {
+	envelope := func(eventType string, version int, payload string) []byte {
+		data, _ := json.Marshal(map[string]any{
+			"type":           eventType,
+			"schema_version": version,
+			"event_id":       "e1",
+			"payload":        json.RawMessage(payload),
+		})
+		return data
+	}
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"not json", []byte("{"), ErrMalformed},
+		{"missing type", envelope("", 1, `{}`), ErrMalformed},
+		{"missing version", envelope(TypePostCreated, 0, `{}`), ErrMalformed},
+		{"missing payload", []byte(`{"type":"post_created","schema_version":1}`), ErrMalformed},
+		{"malformed payload", envelope(TypePostCreated, 1, `{"id":42}`), ErrMalformed},
+		{"unknown type", envelope("post_liked", 1, `{}`), ErrUnknownType},
+		{"unsupported version", envelope(TypePostCreated, 2, `{}`), ErrUnsupportedVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got any
+			err := testRegistry(&got).Dispatch(context.Background(), tt.data)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Dispatch error = %v, want %v", err, tt.want)
+			}
+			if !IsRejected(err) {
+				t.Errorf("IsRejected(%v) = false", err)
+			}
+			if got != nil {
+				t.Errorf("handler ran with %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// handlerFunc processes an envelope whose payload is still encoded.
+type handlerFunc func(ctx context.Context, env Envelope) error
+
+type registryKey struct {
+	eventType string
+	version   int
+}
+
+// Registry dispatches envelopes to the handler registered for their type and
+// schema version.
+type Registry struct {
+	handlers map[registryKey]handlerFunc
+	types    map[string]bool
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[registryKey]handlerFunc),
+		types:    make(map[string]bool),
+	}
+}
+
+// Handle registers fn for an event type and schema version. The payload is
+// decoded into T before fn is called.
+func Handle[T any](r *Registry, eventType string, version int, fn func(ctx context.Context, env Envelope, payload T) error) {
+	r.types[eventType] = true
+	r.handlers[registryKey{eventType, version}] = func(ctx context.Context, env Envelope) error {
+		var payload T
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			return fmt.Errorf("%w: %s v%d payload: %v", ErrMalformed, env.Type, env.SchemaVersion, err)
+		}
+		return fn(ctx, env, payload)
+	}
+}
+
+// Dispatch decodes data and runs the matching handler.
+func (r *Registry) Dispatch(ctx context.Context, data []byte) error {
+	env, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	return r.DispatchEnvelope(ctx, env)
+}
+
+// DispatchEnvelope runs the handler matching an already decoded envelope.
+func (r *Registry) DispatchEnvelope(ctx context.Context, env Envelope) error {
+	h, ok := r.handlers[registryKey{env.Type, env.SchemaVersion}]
+	if !ok {
+		if r.types[env.Type] {
+			return fmt.Errorf("%w: %s v%d", ErrUnsupportedVersion, env.Type, env.SchemaVersion)
+		}
+		return fmt.Errorf("%w: %s", ErrUnknownType, env.Type)
+	}
+	return h(ctx, env)
+}
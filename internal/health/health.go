@@ -0,0 +1,144 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"example.com/cassandrafeed/internal/logger"
+	"example.com/cassandrafeed/internal/metrics"
+)
+
+var logg = logger.New()
+
+// checkTimeout bounds every readiness check, so a hung dependency fails the
+// probe instead of outlasting the orchestrator's own timeout.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether one dependency is usable.
+type Check func(ctx context.Context) error
+
+// Checker answers liveness and readiness probes. The process is live as long
+// as it can answer at all; it is ready while every registered check passes
+// and it is not shutting down.
+type Checker struct {
+	mu       sync.RWMutex
+	checks   map[string]Check
+	shutdown atomic.Bool
+}
+
+// New returns a Checker without checks, which is ready until shut down.
+func New() *Checker {
+	return &Checker{checks: make(map[string]Check)}
+}
+
+// Add registers a readiness check under name.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+}
+
+// ShutdownOn makes the process report not ready as soon as ctx is done, so
+// traffic is drained before the listeners close.
+func (c *Checker) ShutdownOn(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		c.shutdown.Store(true)
+	}()
+}
+
+// Register adds GET /healthz and GET /readyz to mux.
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", c.liveHandler)
+	mux.HandleFunc("GET /readyz", c.readyHandler)
+}
+
+// report is the JSON body of both probes.
+type report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (c *Checker) liveHandler(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, report{Status: "ok"})
+}
+
+// readyHandler runs all checks concurrently and answers 503 if any failed.
+// Failure causes are logged, not returned, since the probe may be reachable
+// from outside.
+func (c *Checker) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if c.shutdown.Load() {
+		writeReport(w, http.StatusServiceUnavailable, report{Status: "shutting_down"})
+		return
+	}
+
+	c.mu.RLock()
+	names := make([]string, 0, len(c.checks))
+	checks := make([]Check, 0, len(c.checks))
+	for name := range c.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		checks = append(checks, c.checks[name])
+	}
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	rep := report{Status: "ok", Checks: make(map[string]string, len(names))}
+	status := http.StatusOK
+	for i, name := range names {
+		rep.Checks[name] = "ok"
+		if errs[i] != nil {
+			logg.Error("health", "Readiness check failed", errs[i], "check", name)
+			rep.Checks[name] = "failing"
+			rep.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	writeReport(w, status, rep)
+}
+
+func writeReport(w http.ResponseWriter, status int, rep report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(rep)
+}
+
+// Serve exposes the probes and /metrics on addr, apart from the public API:
+// the server, worker and relay modes all call it with METRICS_ADDR. The
+// listener is not shut down with the rest of the process, so the probes keep
+// reporting while in-flight work drains.
+func Serve(addr string, c *Checker) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	c.Register(mux)
+	mux.Handle("GET /metrics", metrics.Handler())
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	logg.Info("health", "Serving probes and metrics", "addr", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logg.Error("health", "Probe listener stopped unexpectedly", err)
+	}
+}
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"net/netip"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,6 +14,52 @@ type Config struct {
 	Mode       string
 	ServerAddr string
 
+	// Minimum log level: debug, info, warn or error
+	LogLevel string
+
+	// Listener serving /metrics, /healthz and /readyz in server, worker and
+	// relay mode; the server also answers the probes on ServerAddr (empty
+	// disables)
+	MetricsAddr string
+
+	// How long the server keeps serving after a shutdown signal while
+	// /readyz already fails, so load balancers can stop routing to it
+	ShutdownDrainDelay time.Duration
+
+	// Tracing: spans are exported over OTLP/HTTP to OTLPEndpoint (empty
+	// disables export); TraceSampleRatio of new traces are recorded
+	OTLPEndpoint     string
+	TraceSampleRatio float64
+
+	// How long Idempotency-Key responses of POST /posts are replayed
+	IdempotencyKeyTTL time.Duration
+
+	// Sessions: access and refresh token lifetimes, and how long a token
+	// found not revoked is trusted before the revocation list is checked again
+	AccessTokenTTL     time.Duration
+	RefreshTokenTTL    time.Duration
+	RevocationCacheTTL time.Duration
+
+	// JWT signing keys: PEM files in JWTKeysDir (file name = kid), signing
+	// with JWTSigningKeyID and re-read every JWTKeysReloadInterval. Without a
+	// directory tokens are signed with the shared HS256 secret JWTSecret.
+	JWTKeysDir            string
+	JWTSigningKeyID       string
+	JWTKeysReloadInterval time.Duration
+	JWTSecret             string
+
+	// Rate limits per caller ("<requests>/<duration>", "0" disables):
+	// registration and login are keyed by client IP, the rest by user
+	RateLimitRegister RateLimit
+	RateLimitLogin    RateLimit
+	RateLimitPosts    RateLimit
+	RateLimitFollow   RateLimit
+	RateLimitRead     RateLimit
+
+	// Proxies and load balancers (CIDRs or addresses) whose Forwarded and
+	// X-Forwarded-For headers are trusted to name the client IP
+	TrustedProxies []netip.Prefix
+
 	// Kafka
 	KafkaBroker    string
 	KafkaTopic     string
@@ -19,6 +68,24 @@ type Config struct {
 	KafkaReadTO    time.Duration
 	KafkaWriteTO   time.Duration
 
+	// Retry & dead-letter pipeline
+	KafkaRetryTopic      string
+	KafkaDLQTopic        string
+	WorkerMaxAttempts    int
+	WorkerRetryBackoff   time.Duration
+	DLQReplayIdleTimeout time.Duration
+
+	// How long a worker read loop may go without progress before /readyz fails
+	WorkerStallTimeout time.Duration
+
+	// Worker fan-out
+	FanoutConcurrency int
+	FanoutBatchSize   int
+
+	// Processed events ledger
+	ProcessedEventTTL       time.Duration
+	ProcessedEventCacheSize int
+
 	// Cassandra
 	CassandraHost     string
 	CassandraKeyspace string
@@ -26,6 +93,21 @@ type Config struct {
 	CassandraPassword string
 	CassandraTimeout  time.Duration
 	CassandraDC       string
+
+	// Outbox relay
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+
+	// Hybrid feed: authors with at least this many followers are not fanned
+	// out on write but merged into feeds on read (0 disables)
+	CelebrityFollowerThreshold int
+}
+
+// RateLimit allows Requests requests per Per, in bursts of up to Requests.
+// A zero RateLimit disables rate limiting.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
 }
 
 var cfg *Config
@@ -34,6 +116,21 @@ var cfg *Config
 func Init() *Config {
 	viper.SetDefault("MODE", "server")
 	viper.SetDefault("SERVER_ADDR", ":8080")
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("METRICS_ADDR", ":9090")
+	viper.SetDefault("SHUTDOWN_DRAIN_DELAY", "0s")
+	viper.SetDefault("TRACE_SAMPLE_RATIO", 1.0)
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", "24h")
+	viper.SetDefault("ACCESS_TOKEN_TTL", "15m")
+	viper.SetDefault("REFRESH_TOKEN_TTL", "720h")
+	viper.SetDefault("REVOCATION_CACHE_TTL", "30s")
+	viper.SetDefault("JWT_KEYS_RELOAD_INTERVAL", "1m")
+
+	viper.SetDefault("RATE_LIMIT_REGISTER", "5/1m")
+	viper.SetDefault("RATE_LIMIT_LOGIN", "10/1m")
+	viper.SetDefault("RATE_LIMIT_POSTS", "30/1m")
+	viper.SetDefault("RATE_LIMIT_FOLLOW", "60/1m")
+	viper.SetDefault("RATE_LIMIT_READ", "300/1m")
 
 	viper.SetDefault("KAFKA_BROKER", "localhost:29092")
 	viper.SetDefault("KAFKA_TOPIC", "feed-topic")
@@ -42,11 +139,28 @@ func Init() *Config {
 	viper.SetDefault("KAFKA_READ_TIMEOUT", "10s")
 	viper.SetDefault("KAFKA_WRITE_TIMEOUT", "10s")
 
+	viper.SetDefault("KAFKA_RETRY_TOPIC", "feed-topic.retry")
+	viper.SetDefault("KAFKA_DLQ_TOPIC", "feed-topic.dlq")
+	viper.SetDefault("WORKER_MAX_ATTEMPTS", 3)
+	viper.SetDefault("WORKER_RETRY_BACKOFF", "1s")
+	viper.SetDefault("DLQ_REPLAY_IDLE_TIMEOUT", "10s")
+	viper.SetDefault("WORKER_STALL_TIMEOUT", "1m")
+
+	viper.SetDefault("FANOUT_CONCURRENCY", 20)
+	viper.SetDefault("FANOUT_BATCH_SIZE", 50)
+	viper.SetDefault("PROCESSED_EVENT_TTL", "168h")
+	viper.SetDefault("PROCESSED_EVENT_CACHE_SIZE", 10000)
+
 	viper.SetDefault("CASSANDRA_HOST", "localhost")
 	viper.SetDefault("CASSANDRA_KEYSPACE", "feedapp")
 	viper.SetDefault("CASSANDRA_TIMEOUT", "10s")
 	// Optional: Cassandra username/password/DC can be empty
 
+	viper.SetDefault("OUTBOX_POLL_INTERVAL", "500ms")
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+
+	viper.SetDefault("CELEBRITY_FOLLOWER_THRESHOLD", 10000)
+
 	// Load env variables
 	viper.AutomaticEnv()
 
@@ -58,8 +172,31 @@ func Init() *Config {
 	_ = viper.ReadInConfig() // ignore error if no file
 
 	cfg = &Config{
-		Mode:              viper.GetString("MODE"),
-		ServerAddr:        viper.GetString("SERVER_ADDR"),
+		Mode:               viper.GetString("MODE"),
+		ServerAddr:         viper.GetString("SERVER_ADDR"),
+		LogLevel:           viper.GetString("LOG_LEVEL"),
+		MetricsAddr:        viper.GetString("METRICS_ADDR"),
+		ShutdownDrainDelay: parseDuration(viper.GetString("SHUTDOWN_DRAIN_DELAY"), 0),
+		OTLPEndpoint:       viper.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		TraceSampleRatio:   viper.GetFloat64("TRACE_SAMPLE_RATIO"),
+		IdempotencyKeyTTL:  parseDuration(viper.GetString("IDEMPOTENCY_KEY_TTL"), 24*time.Hour),
+
+		AccessTokenTTL:     parseDuration(viper.GetString("ACCESS_TOKEN_TTL"), 15*time.Minute),
+		RefreshTokenTTL:    parseDuration(viper.GetString("REFRESH_TOKEN_TTL"), 30*24*time.Hour),
+		RevocationCacheTTL: parseDuration(viper.GetString("REVOCATION_CACHE_TTL"), 30*time.Second),
+
+		JWTKeysDir:            viper.GetString("JWT_KEYS_DIR"),
+		JWTSigningKeyID:       viper.GetString("JWT_SIGNING_KEY_ID"),
+		JWTKeysReloadInterval: parseDuration(viper.GetString("JWT_KEYS_RELOAD_INTERVAL"), time.Minute),
+		JWTSecret:             viper.GetString("JWT_SECRET"),
+
+		RateLimitRegister: parseRateLimit(viper.GetString("RATE_LIMIT_REGISTER"), RateLimit{5, time.Minute}),
+		RateLimitLogin:    parseRateLimit(viper.GetString("RATE_LIMIT_LOGIN"), RateLimit{10, time.Minute}),
+		RateLimitPosts:    parseRateLimit(viper.GetString("RATE_LIMIT_POSTS"), RateLimit{30, time.Minute}),
+		RateLimitFollow:   parseRateLimit(viper.GetString("RATE_LIMIT_FOLLOW"), RateLimit{60, time.Minute}),
+		RateLimitRead:     parseRateLimit(viper.GetString("RATE_LIMIT_READ"), RateLimit{300, time.Minute}),
+		TrustedProxies:    parseTrustedProxies(viper.GetString("TRUSTED_PROXIES")),
+
 		KafkaBroker:       viper.GetString("KAFKA_BROKER"),
 		KafkaTopic:        viper.GetString("KAFKA_TOPIC"),
 		KafkaGroupID:      viper.GetString("KAFKA_GROUP_ID"),
@@ -72,6 +209,24 @@ func Init() *Config {
 		CassandraPassword: viper.GetString("CASSANDRA_PASSWORD"),
 		CassandraTimeout:  parseDuration(viper.GetString("CASSANDRA_TIMEOUT"), 10*time.Second),
 		CassandraDC:       viper.GetString("CASSANDRA_DC"),
+
+		OutboxPollInterval: parseDuration(viper.GetString("OUTBOX_POLL_INTERVAL"), 500*time.Millisecond),
+		OutboxBatchSize:    viper.GetInt("OUTBOX_BATCH_SIZE"),
+
+		KafkaRetryTopic:      viper.GetString("KAFKA_RETRY_TOPIC"),
+		KafkaDLQTopic:        viper.GetString("KAFKA_DLQ_TOPIC"),
+		WorkerMaxAttempts:    viper.GetInt("WORKER_MAX_ATTEMPTS"),
+		WorkerRetryBackoff:   parseDuration(viper.GetString("WORKER_RETRY_BACKOFF"), time.Second),
+		DLQReplayIdleTimeout: parseDuration(viper.GetString("DLQ_REPLAY_IDLE_TIMEOUT"), 10*time.Second),
+		WorkerStallTimeout:   parseDuration(viper.GetString("WORKER_STALL_TIMEOUT"), time.Minute),
+
+		FanoutConcurrency: viper.GetInt("FANOUT_CONCURRENCY"),
+		FanoutBatchSize:   viper.GetInt("FANOUT_BATCH_SIZE"),
+
+		ProcessedEventTTL:       parseDuration(viper.GetString("PROCESSED_EVENT_TTL"), 7*24*time.Hour),
+		ProcessedEventCacheSize: viper.GetInt("PROCESSED_EVENT_CACHE_SIZE"),
+
+		CelebrityFollowerThreshold: viper.GetInt("CELEBRITY_FOLLOWER_THRESHOLD"),
 	}
 
 	return cfg
@@ -84,6 +239,38 @@ func parseDuration(s string, def time.Duration) time.Duration {
 	return def
 }
 
+// parseRateLimit parses "<requests>/<duration>", e.g. "30/1m"; "0" disables.
+func parseRateLimit(s string, def RateLimit) RateLimit {
+	if s == "0" {
+		return RateLimit{}
+	}
+	n, per, ok := strings.Cut(s, "/")
+	requests, err := strconv.Atoi(n)
+	if !ok || err != nil || requests <= 0 {
+		return def
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return RateLimit{Requests: requests, Per: d}
+}
+
+// parseTrustedProxies parses a comma separated list of CIDRs and addresses,
+// e.g. "10.0.0.0/8,192.168.1.10". Invalid entries are skipped.
+func parseTrustedProxies(s string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		} else if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
 // Get returns the loaded config instance
 func Get() *Config {
 	return cfg
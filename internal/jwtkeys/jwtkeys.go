@@ -0,0 +1,366 @@
+package jwtkeys
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"example.com/cassandrafeed/internal/logger"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var logg = logger.New()
+
+// ErrUnknownKey is returned when a token names a key that is not loaded.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// PublishDelay is how long a key must have been in the key directory before
+// it signs tokens. It matches the max-age of the JWKS response, so verifiers
+// caching the JWKS know the key before the first token signed with it.
+const PublishDelay = 5 * time.Minute
+
+// currentFile names the file in the key directory that holds the kid of the
+// signing key. It is re-read on every reload, so the signing key can be
+// switched without a restart.
+const currentFile = "current"
+
+// key is one signing or verification key, identified by its kid.
+type key struct {
+	id      string
+	method  jwt.SigningMethod
+	signer  crypto.Signer // nil for verification-only keys
+	public  crypto.PublicKey
+	modTime time.Time // when the key file was written, i.e. published
+}
+
+// KeySet signs access tokens with one key and verifies them with any loaded
+// key, so keys can be rotated without invalidating tokens in flight.
+//
+// Keys are PEM files in a directory; the file name without ".pem" is the kid.
+// Private keys (PKCS#8 RSA or Ed25519, or PKCS#1 RSA) can sign and verify;
+// public keys (PKIX) only verify. RSA keys sign RS256, Ed25519 keys EdDSA.
+//
+// The signing key is the kid in the file "current" in the directory, else the
+// configured one, else the newest private key. A key only starts signing
+// once it has been in the directory for PublishDelay; until then the
+// previous signing key stays in use.
+//
+// Without a directory, the set falls back to a shared HMAC secret (HS256),
+// which is only suitable for development: it cannot be published as JWKS.
+type KeySet struct {
+	dir          string
+	configuredID string
+	secret       []byte
+
+	mu        sync.RWMutex
+	keys      map[string]key
+	signingID string
+}
+
+// NewHMAC returns a key set that signs and verifies HS256 tokens with secret.
+// An empty secret is refused, as anyone could forge tokens with it.
+func NewHMAC(secret []byte) (*KeySet, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("jwtkeys: empty HMAC secret")
+	}
+	return &KeySet{secret: secret}, nil
+}
+
+// LoadDir loads the keys in dir and signs with the key named signingID,
+// unless the directory names another one in its "current" file. signingID
+// may be empty to sign with the newest private key.
+func LoadDir(dir, signingID string) (*KeySet, error) {
+	ks := &KeySet{dir: dir, configuredID: signingID}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload re-reads the key directory. Keys added to the directory become
+// valid for verification and appear in the JWKS; removed keys stop being
+// accepted. The signing key is chosen again, see KeySet. On error the
+// previously loaded keys stay in use.
+func (ks *KeySet) Reload() error {
+	if ks.dir == "" {
+		return nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	ks.mu.RLock()
+	old, prevID := ks.keys, ks.signingID
+	ks.mu.RUnlock()
+
+	keys := make(map[string]key, len(paths))
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		k, ok := old[id]
+		if !ok || !k.modTime.Equal(info.ModTime()) {
+			if k, err = loadKey(path, id); err != nil {
+				return err
+			}
+			k.modTime = info.ModTime()
+		}
+		keys[id] = k
+	}
+
+	wantID, err := ks.wantedID()
+	if err != nil {
+		return err
+	}
+	signingID, err := pickSigner(keys, wantID, prevID, time.Now())
+	if err != nil {
+		return fmt.Errorf("jwtkeys: %s: %w", ks.dir, err)
+	}
+	if signingID != prevID {
+		logg.Info("jwtkeys", "Signing key selected", "kid", signingID)
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.signingID = signingID
+	ks.mu.Unlock()
+	return nil
+}
+
+// wantedID returns the kid in the "current" file, or the configured one if
+// there is no such file.
+func (ks *KeySet) wantedID() (string, error) {
+	data, err := os.ReadFile(filepath.Join(ks.dir, currentFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return ks.configuredID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if id := strings.TrimSpace(string(data)); id != "" {
+		return id, nil
+	}
+	return ks.configuredID, nil
+}
+
+// pickSigner chooses the signing key among keys. wantID, if set, must be a
+// private key; it signs once published, before that prevID keeps signing.
+// Without wantID the newest published private key signs. A key that is not
+// published yet is only used when no other private key can sign.
+func pickSigner(keys map[string]key, wantID, prevID string, now time.Time) (string, error) {
+	var signers []key
+	for _, k := range keys {
+		if k.signer != nil {
+			signers = append(signers, k)
+		}
+	}
+	if len(signers) == 0 {
+		return "", errors.New("no private key")
+	}
+	// Oldest first, by kid for keys written at the same time
+	sort.Slice(signers, func(i, j int) bool {
+		if !signers[i].modTime.Equal(signers[j].modTime) {
+			return signers[i].modTime.Before(signers[j].modTime)
+		}
+		return signers[i].id < signers[j].id
+	})
+	published := func(k key) bool { return now.Sub(k.modTime) >= PublishDelay }
+	prev, hasPrev := keys[prevID]
+	hasPrev = hasPrev && prev.signer != nil
+
+	if wantID != "" {
+		want, ok := keys[wantID]
+		if !ok || want.signer == nil {
+			return "", fmt.Errorf("no private key %q", wantID)
+		}
+		if published(want) {
+			return wantID, nil
+		}
+		if hasPrev {
+			return prevID, nil
+		}
+	}
+
+	for i := len(signers) - 1; i >= 0; i-- {
+		if published(signers[i]) {
+			return signers[i].id, nil
+		}
+	}
+	if wantID != "" {
+		return wantID, nil
+	}
+	if hasPrev {
+		return prevID, nil
+	}
+	return signers[0].id, nil
+}
+
+// Watch reloads the key directory every interval until ctx is done.
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	if ks.dir == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				logg.Error("jwtkeys", "Failed to reload signing keys", err)
+			}
+		}
+	}
+}
+
+// Sign returns a signed token for claims, with the signing key in the kid header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if ks.dir == "" {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(ks.secret)
+	}
+
+	ks.mu.RLock()
+	k := ks.keys[ks.signingID]
+	ks.mu.RUnlock()
+
+	token := jwt.NewWithClaims(k.method, claims)
+	token.Header["kid"] = k.id
+	return token.SignedString(k.signer)
+}
+
+// Keyfunc resolves the key that verifies token, for use with jwt.Parse.
+// A token must name a loaded key in its kid header and use that key's algorithm.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if ks.dir == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return ks.secret, nil
+	}
+
+	id, _ := token.Header["kid"].(string)
+	ks.mu.RLock()
+	k, ok := ks.keys[id]
+	ks.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.public, nil
+}
+
+// Methods lists the signing algorithms the set accepts.
+func (ks *KeySet) Methods() []string {
+	if ks.dir == "" {
+		return []string{jwt.SigningMethodHS256.Alg()}
+	}
+	return []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517, RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is the document served at /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of all loaded keys, sorted by kid.
+// An HMAC-only set has no public keys.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		set.Keys = append(set.Keys, toJWK(k))
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
+
+func toJWK(k key) JWK {
+	jwk := JWK{KeyID: k.id, Use: "sig", Algorithm: k.method.Alg()}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
+
+// loadKey parses the PEM key file at path.
+func loadKey(path, id string) (key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return key{}, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return key{}, fmt.Errorf("jwtkeys: %s is not a PEM file", path)
+	}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return key{}, fmt.Errorf("jwtkeys: %s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return key{}, fmt.Errorf("jwtkeys: %s: %w", path, err)
+	}
+
+	k := key{id: id}
+	switch pk := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.signer, k.public = jwt.SigningMethodRS256, pk, &pk.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, pk
+	case ed25519.PrivateKey:
+		k.method, k.signer, k.public = jwt.SigningMethodEdDSA, pk, pk.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, pk
+	default:
+		return key{}, fmt.Errorf("jwtkeys: %s: unsupported key type %T", path, parsed)
+	}
+	return k, nil
+}
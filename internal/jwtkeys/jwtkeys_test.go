@@ -0,0 +1,168 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey writes a new Ed25519 private key named kid, last modified age ago.
+func writeKey(t *testing.T, dir, kid string, age time.Duration) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, kid+".pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setAge(t, dir, kid, age)
+}
+
+// setAge backdates the key file named kid.
+func setAge(t *testing.T, dir, kid string, age time.Duration) {
+	t.Helper()
+	mtime := time.Now().Add(-age)
+	if err := os.Chtimes(filepath.Join(dir, kid+".pem"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// signingKID signs a token and returns the kid it names.
+func signingKID(t *testing.T, ks *KeySet) string {
+	t.Helper()
+	token, err := ks.Sign(jwt.MapClaims{"user_id": "1"})
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	parsed, err := jwt.Parse(token, ks.Keyfunc, jwt.WithValidMethods(ks.Methods()))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid
+}
+
+func reload(t *testing.T, ks *KeySet) {
+	t.Helper()
+	if err := ks.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+}
+
+func TestNewHMAC_RefusesEmptySecret(t *testing.T) {
+	if _, err := NewHMAC(nil); err == nil {
+		t.Fatal("expected an error for an empty secret")
+	}
+	if _, err := NewHMAC([]byte("secret")); err != nil {
+		t.Fatalf("NewHMAC failed: %v", err)
+	}
+}
+
+func TestLoadDir_SingleKeyWithoutID(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", 0)
+
+	ks, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	if kid := signingKID(t, ks); kid != "a" {
+		t.Errorf("signing kid = %q, want a", kid)
+	}
+}
+
+// without a configured ID, an added private key does not break reloads and
+// takes over once it has been published
+func TestReload_NewestPublishedKeySigns(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", time.Hour)
+	ks, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	writeKey(t, dir, "b", 0)
+	reload(t, ks)
+	if kid := signingKID(t, ks); kid != "a" {
+		t.Errorf("signing kid before publishing = %q, want a", kid)
+	}
+
+	setAge(t, dir, "b", PublishDelay)
+	reload(t, ks)
+	if kid := signingKID(t, ks); kid != "b" {
+		t.Errorf("signing kid after publishing = %q, want b", kid)
+	}
+}
+
+// the current file switches the signing key without a restart, once the key
+// has been published
+func TestReload_CurrentFile(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", time.Hour)
+	writeKey(t, dir, "b", 0)
+	ks, err := LoadDir(dir, "a")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, currentFile), []byte("b\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reload(t, ks)
+	if kid := signingKID(t, ks); kid != "a" {
+		t.Errorf("signing kid before publishing = %q, want a", kid)
+	}
+
+	setAge(t, dir, "b", PublishDelay)
+	reload(t, ks)
+	if kid := signingKID(t, ks); kid != "b" {
+		t.Errorf("signing kid after publishing = %q, want b", kid)
+	}
+}
+
+func TestReload_UnknownSigningKeyKeepsKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a", time.Hour)
+	ks, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, currentFile), []byte("missing"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.Reload(); err == nil {
+		t.Fatal("expected an error for an unknown signing key")
+	}
+	if kid := signingKID(t, ks); kid != "a" {
+		t.Errorf("signing kid = %q, want a", kid)
+	}
+}
+
+func TestJWKS_ListsAllKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "b", 0)
+	writeKey(t, dir, "a", 0)
+	ks, err := LoadDir(dir, "")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+
+	jwks := ks.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "a" || jwks.Keys[1].KeyID != "b" || jwks.Keys[0].Curve != "Ed25519" {
+		t.Errorf("unexpected JWKS: %+v", jwks)
+	}
+}
@@ -1,83 +1,104 @@
 package logger
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	"log/slog"
 	"os"
-	"regexp"
-	"time"
 )
 
-type LogLevel string
+// level is shared by all loggers, which are created as package variables
+// before the configuration is loaded.
+var level = new(slog.LevelVar)
 
-const (
-	InfoLevel  LogLevel = "INFO"
-	ErrorLevel LogLevel = "ERROR"
-	DebugLevel LogLevel = "DEBUG"
-)
+// base writes JSON lines to stdout, tagged with the request and trace IDs of
+// the context and with sensitive values redacted.
+var base slog.Handler = contextHandler{slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+	Level:       level,
+	ReplaceAttr: replaceAttr,
+})}
 
-// LogEntry describes the structure of a log message
-type LogEntry struct {
-	Time    string   `json:"time"`
-	Level   LogLevel `json:"level"`
-	Module  string   `json:"module,omitempty"`
-	Message string   `json:"message"`
-	Error   string   `json:"error,omitempty"`
+// SetLevel sets the minimum level of all loggers: "debug", "info", "warn" or
+// "error". The default is "info".
+func SetLevel(name string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
 }
 
-// Logger is a centralized structured logger
+// Logger is a centralized structured logger. Besides the module and message,
+// every method takes key/value pairs like slog, e.g.
+//
+//	logg.Info("worker", "Post delivered to followers", "followers", n)
+//
+// The Context variants also record the request and trace IDs found in ctx.
 type Logger struct {
-	out *log.Logger
+	l *slog.Logger
 }
 
 // New creates a new Logger
 func New() *Logger {
-	return &Logger{
-		out: log.New(os.Stdout, "", 0),
-	}
+	return &Logger{l: slog.New(base)}
 }
 
-// Anonymize replaces sensitive information in logs (emails, tokens, IDs)
-func Anonymize(s string) string {
-	// Replace emails with [REDACTED_EMAIL]
-	emailRegex := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
-	s = emailRegex.ReplaceAllString(s, "[REDACTED_EMAIL]")
+// With returns a Logger that adds args to every line.
+func (l *Logger) With(args ...any) *Logger {
+	return &Logger{l: l.l.With(args...)}
+}
 
-	// Replace JWT tokens (simple pattern) with [REDACTED_TOKEN]
-	tokenRegex := regexp.MustCompile(`eyJ[^\s]+`)
-	s = tokenRegex.ReplaceAllString(s, "[REDACTED_TOKEN]")
+// Sampled returns a Logger for hot paths: per message and second it writes
+// the first lines and then every thereafter-th. Errors are never dropped.
+func (l *Logger) Sampled(first, thereafter int) *Logger {
+	return &Logger{l: slog.New(newSamplingHandler(l.l.Handler(), first, thereafter))}
+}
 
-	// Replace user IDs with [USER_ID]
-	userIDRegex := regexp.MustCompile(`\buser_id\s*=\s*\d+\b`)
-	s = userIDRegex.ReplaceAllString(s, "user_id=[USER_ID]")
+// --- Convenient methods ---
+func (l *Logger) Debug(module, msg string, args ...any) {
+	l.log(context.Background(), slog.LevelDebug, module, msg, nil, args)
+}
 
-	return s
+func (l *Logger) Info(module, msg string, args ...any) {
+	l.log(context.Background(), slog.LevelInfo, module, msg, nil, args)
 }
 
-// internal log function
-func (l *Logger) log(module string, level LogLevel, msg string, err error) {
-	entry := LogEntry{
-		Time:    time.Now().Format(time.RFC3339),
-		Level:   level,
-		Module:  module,
-		Message: Anonymize(msg),
-	}
-	if err != nil {
-		entry.Error = Anonymize(err.Error())
-	}
-	data, _ := json.Marshal(entry)
-	l.out.Println(string(data))
+func (l *Logger) Warn(module, msg string, args ...any) {
+	l.log(context.Background(), slog.LevelWarn, module, msg, nil, args)
 }
 
-// --- Convenient methods ---
-func (l *Logger) Info(module, msg string) {
-	l.log(module, InfoLevel, msg, nil)
+func (l *Logger) Error(module, msg string, err error, args ...any) {
+	l.log(context.Background(), slog.LevelError, module, msg, err, args)
 }
 
-func (l *Logger) Debug(module, msg string) {
-	l.log(module, DebugLevel, msg, nil)
+func (l *Logger) DebugContext(ctx context.Context, module, msg string, args ...any) {
+	l.log(ctx, slog.LevelDebug, module, msg, nil, args)
 }
 
-func (l *Logger) Error(module, msg string, err error) {
-	l.log(module, ErrorLevel, msg, err)
+func (l *Logger) InfoContext(ctx context.Context, module, msg string, args ...any) {
+	l.log(ctx, slog.LevelInfo, module, msg, nil, args)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, module, msg string, args ...any) {
+	l.log(ctx, slog.LevelWarn, module, msg, nil, args)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, module, msg string, err error, args ...any) {
+	l.log(ctx, slog.LevelError, module, msg, err, args)
+}
+
+// internal log function; disabled levels return before any formatting
+func (l *Logger) log(ctx context.Context, lvl slog.Level, module, msg string, err error, args []any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !l.l.Enabled(ctx, lvl) {
+		return
+	}
+	attrs := make([]any, 0, len(args)+2)
+	attrs = append(attrs, slog.String("module", module))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.l.Log(ctx, lvl, msg, append(attrs, args...)...)
 }
@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/cassandrafeed/internal/requestid"
+)
+
+// newTestLogger returns a Logger configured like New that writes to buf.
+func newTestLogger(buf *bytes.Buffer) *Logger {
+	return &Logger{l: slog.New(contextHandler{slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: replaceAttr,
+	})})}
+}
+
+// lines decodes the JSON lines written to buf.
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func setLevel(t *testing.T, name string) {
+	t.Helper()
+	prev := level.Level()
+	t.Cleanup(func() { level.Set(prev) })
+	if err := SetLevel(name); err != nil {
+		t.Fatalf("SetLevel(%q): %v", name, err)
+	}
+}
+
+func TestSetLevel_FiltersLines(t *testing.T) {
+	setLevel(t, "warn")
+	var buf bytes.Buffer
+	logg := newTestLogger(&buf)
+
+	logg.Debug("test", "debug line")
+	logg.Info("test", "info line")
+	logg.Warn("test", "warn line")
+	logg.Error("test", "error line", errors.New("boom"))
+
+	got := lines(t, &buf)
+	if len(got) != 2 || got[0]["message"] != "warn line" || got[1]["message"] != "error line" {
+		t.Fatalf("expected only the warn and error lines, got %v", got)
+	}
+	if got[1]["error"] != "boom" || got[1]["module"] != "test" {
+		t.Errorf("error line is missing its module or error: %v", got[1])
+	}
+
+	if err := SetLevel("loud"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	s := &sampler{first: 2, thereafter: 3}
+	now := time.Now()
+
+	var kept []int
+	for i := 1; i <= 8; i++ {
+		if s.allow("hot", now) {
+			kept = append(kept, i)
+		}
+	}
+	if want := []int{1, 2, 5, 8}; !slices.Equal(kept, want) {
+		t.Fatalf("kept lines %v, want %v", kept, want)
+	}
+
+	if !s.allow("other", now) {
+		t.Error("a different message must have its own count")
+	}
+	if !s.allow("hot", now.Add(sampleTick+time.Millisecond)) {
+		t.Error("the count must start over in the next tick")
+	}
+}
+
+func TestSampled_KeepsErrors(t *testing.T) {
+	var buf bytes.Buffer
+	logg := newTestLogger(&buf).Sampled(1, 0)
+
+	for i := 0; i < 3; i++ {
+		logg.Info("test", "hot path")
+		logg.Error("test", "hot failure", errors.New("boom"))
+	}
+
+	infos, errs := 0, 0
+	for _, line := range lines(t, &buf) {
+		switch line["message"] {
+		case "hot path":
+			infos++
+		case "hot failure":
+			errs++
+		}
+	}
+	if infos != 1 || errs != 3 {
+		t.Fatalf("expected 1 info and 3 error lines, got %d and %d", infos, errs)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	logg := newTestLogger(&buf)
+
+	const jwt = "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.sig"
+	logg.Info("test", "Login by alice@example.com with user_id=42",
+		"password", "hunter2",
+		"user_id", "42",
+		"header", "Bearer "+jwt,
+	)
+	logg.Error("test", "Request failed", errors.New("token "+jwt+" expired"))
+
+	got := lines(t, &buf)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 lines, got %v", got)
+	}
+	if msg := got[0]["message"]; msg != "Login by [REDACTED_EMAIL] with user_id=[USER_ID]" {
+		t.Errorf("message not redacted: %q", msg)
+	}
+	if got[0]["password"] != "[REDACTED]" || got[0]["user_id"] != "[USER_ID]" {
+		t.Errorf("sensitive keys not redacted: %v", got[0])
+	}
+	if got[0]["header"] != "Bearer [REDACTED_TOKEN]" {
+		t.Errorf("token in a value not redacted: %q", got[0]["header"])
+	}
+	if got[1]["error"] != "token [REDACTED_TOKEN] expired" {
+		t.Errorf("token in an error not redacted: %q", got[1]["error"])
+	}
+	if strings.Contains(buf.String(), "hunter2") || strings.Contains(buf.String(), "eyJ") {
+		t.Errorf("secret leaked into the log: %s", buf.String())
+	}
+}
+
+func TestUserRef(t *testing.T) {
+	var buf bytes.Buffer
+	logg := newTestLogger(&buf)
+
+	const id = "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	logg.Info("test", "first", UserRef(id))
+	logg.Info("test", "second", UserRef(id))
+	logg.Info("test", "other", UserRef("6ba7b811-9dad-11d1-80b4-00c04fd430c8"))
+
+	got := lines(t, &buf)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 lines, got %v", got)
+	}
+	ref, _ := got[0]["user_ref"].(string)
+	if len(ref) != 16 || strings.HasPrefix(ref, "[") {
+		t.Fatalf("user_ref = %q, want a 16 character hash", ref)
+	}
+	if got[1]["user_ref"] != ref {
+		t.Errorf("user_ref not stable: %q != %q", got[1]["user_ref"], ref)
+	}
+	if got[2]["user_ref"] == ref {
+		t.Errorf("different users share user_ref %q", ref)
+	}
+	if strings.Contains(buf.String(), id) {
+		t.Errorf("user ID leaked into the log: %s", buf.String())
+	}
+}
+
+func TestContext_AddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logg := newTestLogger(&buf)
+
+	logg.InfoContext(requestid.WithID(context.Background(), "req-1"), "test", "tagged")
+	logg.InfoContext(context.Background(), "test", "untagged")
+
+	got := lines(t, &buf)
+	if len(got) != 2 || got[0]["request_id"] != "req-1" {
+		t.Fatalf("expected the request ID on the first line, got %v", got)
+	}
+	if _, ok := got[1]["request_id"]; ok {
+		t.Errorf("line without a request ID in its context was tagged: %v", got[1])
+	}
+}
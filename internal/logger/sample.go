@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// sampleTick is the window the sampling counts are kept for.
+	sampleTick = time.Second
+	// sampleBuckets bounds the memory of a sampler; messages whose hashes
+	// collide share a count.
+	sampleBuckets = 256
+)
+
+// samplingHandler drops lines below ERROR once their message has been
+// logged first times in the current tick, keeping every thereafter-th.
+type samplingHandler struct {
+	slog.Handler
+	s *sampler
+}
+
+func newSamplingHandler(h slog.Handler, first, thereafter int) slog.Handler {
+	return samplingHandler{Handler: h, s: &sampler{first: first, thereafter: thereafter}}
+}
+
+func (h samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError && !h.s.allow(r.Message, r.Time) {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return samplingHandler{Handler: h.Handler.WithAttrs(attrs), s: h.s}
+}
+
+func (h samplingHandler) WithGroup(name string) slog.Handler {
+	return samplingHandler{Handler: h.Handler.WithGroup(name), s: h.s}
+}
+
+// sampler counts lines per message within a tick.
+type sampler struct {
+	first, thereafter int
+
+	mu      sync.Mutex
+	buckets [sampleBuckets]struct {
+		until time.Time
+		n     int
+	}
+}
+
+// allow counts a line with msg logged at now and reports whether to keep it.
+func (s *sampler) allow(msg string, now time.Time) bool {
+	h := fnv.New32a()
+	h.Write([]byte(msg))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b := &s.buckets[h.Sum32()%sampleBuckets]
+	if now.After(b.until) {
+		b.until = now.Add(sampleTick)
+		b.n = 0
+	}
+	b.n++
+	if b.n <= s.first {
+		return true
+	}
+	return s.thereafter > 0 && (b.n-s.first)%s.thereafter == 0
+}
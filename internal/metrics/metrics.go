@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics are registered with the default Prometheus registry, which also
+// carries the Go runtime and process collectors.
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "feed", Subsystem: "http", Name: "requests_total",
+		Help: "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "feed", Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "HTTP request latency by route and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	kafkaWriteDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "feed", Subsystem: "kafka", Name: "write_duration_seconds",
+		Help:    "Latency of Kafka produce calls by topic.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"topic"})
+
+	kafkaWriteErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "feed", Subsystem: "kafka", Name: "write_errors_total",
+		Help: "Failed Kafka produce calls by topic.",
+	}, []string{"topic"})
+
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "feed", Subsystem: "kafka", Name: "consumer_lag",
+		Help: "Messages behind the partition end as of the last fetched message.",
+	}, []string{"topic", "partition"})
+
+	workerMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "feed", Subsystem: "worker", Name: "messages_total",
+		Help: "Messages handled by the worker by topic and result (processed, retried, dead_lettered, dropped, failed).",
+	}, []string{"topic", "result"})
+
+	fanoutFollowers = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "feed", Subsystem: "worker", Name: "fanout_followers",
+		Help:    "Followers a post event was fanned out to.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 10),
+	})
+
+	fanoutDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "feed", Subsystem: "worker", Name: "fanout_duration_seconds",
+		Help:    "Time to fan a post event out to all followers.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 14),
+	})
+
+	cassandraDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "feed", Subsystem: "cassandra", Name: "query_duration_seconds",
+		Help:    "Cassandra query and batch latency by operation and table.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"op", "table"})
+
+	cassandraErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "feed", Subsystem: "cassandra", Name: "query_errors_total",
+		Help: "Failed Cassandra queries and batches by operation and table.",
+	}, []string{"op", "table"})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveHTTP records one served request.
+func ObserveHTTP(route, method string, status int, d time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(d.Seconds())
+}
+
+// ObserveKafkaWrite records one produce call to topic.
+func ObserveKafkaWrite(topic string, d time.Duration, err error) {
+	kafkaWriteDuration.WithLabelValues(topic).Observe(d.Seconds())
+	if err != nil {
+		kafkaWriteErrors.WithLabelValues(topic).Inc()
+	}
+}
+
+// SetConsumerLag records how far a consumer is behind on a partition.
+func SetConsumerLag(topic string, partition int, lag int64) {
+	consumerLag.WithLabelValues(topic, strconv.Itoa(partition)).Set(float64(max(lag, 0)))
+}
+
+// CountMessage records the outcome of handling one message from topic.
+func CountMessage(topic, result string) {
+	workerMessages.WithLabelValues(topic, result).Inc()
+}
+
+// ObserveFanout records one fan-out to followers.
+func ObserveFanout(followers int, d time.Duration) {
+	fanoutFollowers.Observe(float64(followers))
+	fanoutDuration.Observe(d.Seconds())
+}
+
+// ObserveCassandra records one Cassandra query or batch.
+func ObserveCassandra(op, table string, d time.Duration, err error) {
+	cassandraDuration.WithLabelValues(op, table).Observe(d.Seconds())
+	if err != nil {
+		cassandraErrors.WithLabelValues(op, table).Inc()
+	}
+}
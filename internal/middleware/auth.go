@@ -2,59 +2,100 @@ package middleware
 
 import (
 	"context"
-	"errors"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 
+	"example.com/cassandrafeed/internal/apierr"
+	"example.com/cassandrafeed/internal/jwtkeys"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type contextKey string
 
-const UserCtxKey = contextKey("user_id")
-
-func JWTAuth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		jwtSecret := []byte(os.Getenv("JWT_SECRET"))
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "missing Authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "invalid Authorization header", http.StatusUnauthorized)
-			return
-		}
-
-		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, errors.New("unexpected signing method")
+const (
+	UserCtxKey  = contextKey("user_id")
+	TokenCtxKey = contextKey("token")
+)
+
+// Token identifies the access token a request was authenticated with.
+type Token struct {
+	ID        string // jti claim
+	SessionID string // sid claim
+	ExpiresAt time.Time
+}
+
+// RevocationList reports whether an access token or its session has been
+// revoked.
+type RevocationList interface {
+	IsRevoked(token Token) (bool, error)
+}
+
+// JWTAuth accepts requests carrying a valid, unexpired and unrevoked access
+// token signed by one of keys. Tokens without a jti or sid claim cannot be
+// revoked and are rejected.
+func JWTAuth(keys *jwtkeys.KeySet, revocations RevocationList) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				apierr.Write(w, r, apierr.Unauthorized("missing Authorization header"))
+				return
+			}
+
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				apierr.Write(w, r, apierr.Unauthorized("invalid Authorization header"))
+				return
+			}
+
+			token, err := jwt.Parse(parts[1], keys.Keyfunc,
+				jwt.WithValidMethods(keys.Methods()), jwt.WithExpirationRequired())
+			if err != nil || !token.Valid {
+				apierr.Write(w, r, apierr.Unauthorized("invalid token"))
+				return
+			}
+
+			claims, ok := token.Claims.(jwt.MapClaims)
+			if !ok {
+				apierr.Write(w, r, apierr.Unauthorized("invalid token claims"))
+				return
 			}
-			return jwtSecret, nil
+
+			userID, ok := claims["user_id"].(string)
+			if !ok {
+				apierr.Write(w, r, apierr.Unauthorized("invalid user_id in token"))
+				return
+			}
+
+			tokenID, ok := claims["jti"].(string)
+			if !ok || tokenID == "" {
+				apierr.Write(w, r, apierr.Unauthorized("invalid jti in token"))
+				return
+			}
+			sessionID, ok := claims["sid"].(string)
+			if !ok || sessionID == "" {
+				apierr.Write(w, r, apierr.Unauthorized("invalid sid in token"))
+				return
+			}
+			exp, _ := claims.GetExpirationTime()
+			tok := Token{ID: tokenID, SessionID: sessionID, ExpiresAt: exp.Time}
+
+			revoked, err := revocations.IsRevoked(tok)
+			if err != nil {
+				apierr.Write(w, r, err)
+				return
+			}
+			if revoked {
+				apierr.Write(w, r, apierr.Unauthorized("token has been revoked"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), UserCtxKey, userID)
+			ctx = context.WithValue(ctx, TokenCtxKey, tok)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
-		if err != nil || !token.Valid {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "invalid token claims", http.StatusUnauthorized)
-			return
-		}
-
-		userID, ok := claims["user_id"].(string)
-		if !ok {
-			http.Error(w, "invalid user_id in token", http.StatusUnauthorized)
-			return
-		}
-
-		ctx := context.WithValue(r.Context(), UserCtxKey, userID)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+	}
 }
 
 // Extracting user_id in handler
@@ -62,3 +103,9 @@ func UserIDFromContext(ctx context.Context) (string, bool) {
 	id, ok := ctx.Value(UserCtxKey).(string)
 	return id, ok
 }
+
+// TokenFromContext returns the access token the request was authenticated with.
+func TokenFromContext(ctx context.Context) (Token, bool) {
+	tok, ok := ctx.Value(TokenCtxKey).(Token)
+	return tok, ok
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const ClientIPCtxKey = contextKey("client_ip")
+
+// ClientIP records the IP of the client that sent a request. It is the peer
+// address, unless the peer is one of the trusted proxies: then the Forwarded
+// header, or X-Forwarded-For without it, is walked from the nearest hop back
+// to the first address that is not a trusted proxy. Headers sent by any
+// other peer are ignored, so clients cannot pick their own IP.
+func ClientIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClientIPCtxKey, ip)))
+		})
+	}
+}
+
+// ClientIPFromContext returns the client IP recorded by ClientIP.
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(ClientIPCtxKey).(string)
+	return ip, ok
+}
+
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	host := remoteHost(r)
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	ip = ip.Unmap()
+
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0 && isTrusted(ip, trusted); i-- {
+		hop, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// "unknown" or an obfuscated identifier: the hop the proxy
+			// connected to is as far as the chain can be followed
+			break
+		}
+		ip = hop.Unmap()
+	}
+	return ip.String()
+}
+
+// remoteHost is the address of the peer that connected to the server.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func isTrusted(ip netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the client addresses listed by proxies, the client
+// first: the for= parameters of Forwarded (RFC 7239) if that header is
+// present, the X-Forwarded-For list otherwise. Ports and brackets are
+// stripped.
+func forwardedFor(h http.Header) []string {
+	var hops []string
+	if values := h.Values("Forwarded"); len(values) > 0 {
+		for _, value := range values {
+			for _, element := range strings.Split(value, ",") {
+				for _, pair := range strings.Split(element, ";") {
+					key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+					if ok && strings.EqualFold(key, "for") {
+						hops = append(hops, stripPort(strings.Trim(val, `"`)))
+					}
+				}
+			}
+		}
+		return hops
+	}
+	for _, value := range h.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(value, ",") {
+			hops = append(hops, stripPort(strings.TrimSpace(hop)))
+		}
+	}
+	return hops
+}
+
+// stripPort removes the port and IPv6 brackets from a node, e.g.
+// "[2001:db8::1]:4711" or "192.0.2.43:80".
+func stripPort(node string) string {
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+}
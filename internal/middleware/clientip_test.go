@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	cases := []struct {
+		name   string
+		remote string
+		header string
+		value  string
+		want   string
+	}{
+		{"no proxy", "203.0.113.7:5000", "", "", "203.0.113.7"},
+		{"untrusted peer cannot spoof", "203.0.113.7:5000", "X-Forwarded-For", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", "X-Forwarded-For", "198.51.100.1", "198.51.100.1"},
+		{"client prepends a fake hop", "10.0.0.2:5000", "X-Forwarded-For", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.2:5000", "X-Forwarded-For", "198.51.100.1, 10.0.0.9", "198.51.100.1"},
+		{"forwarded", "10.0.0.2:5000", "Forwarded", `for="[2001:db8::1]:4711";proto=https, for=10.0.0.9`, "2001:db8::1"},
+		{"unknown hop", "10.0.0.2:5000", "Forwarded", "for=unknown", "10.0.0.2"},
+		{"trusted proxy without header", "10.0.0.2:5000", "", "", "10.0.0.2"},
+	}
+
+	for _, tc := range cases {
+		var got string
+		h := ClientIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = ClientIPFromContext(r.Context())
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tc.want {
+			t.Errorf("%s: client IP = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"example.com/cassandrafeed/internal/apierr"
+	"example.com/cassandrafeed/internal/logger"
+	"example.com/cassandrafeed/internal/models"
+)
+
+var logg = logger.New()
+
+// IdempotencyHeader is the request header carrying a client-chosen key that
+// makes retries of the same request safe.
+const IdempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLen bounds the keys clients may send.
+const maxIdempotencyKeyLen = 255
+
+// maxIdempotentBodyBytes bounds the request bodies read to hash a request.
+const maxIdempotentBodyBytes = 1 << 20
+
+// idempotencyLockTTL is how long a key stays reserved for a request that has
+// not finished. It outlasts the server's write timeout, and a request that
+// crashed the process or lost its response frees the key when it expires.
+const idempotencyLockTTL = 30 * time.Second
+
+// IdempotencyStore persists Idempotency-Key reservations and their responses.
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(userID, key, requestHash string, lockTTL time.Duration) (models.IdempotentResponse, bool, error)
+	CompleteIdempotencyKey(userID, key string, resp models.IdempotentResponse, ttl time.Duration) error
+	ReleaseIdempotencyKey(userID, key, requestHash string) error
+}
+
+// Idempotency replays the stored response when an authenticated user repeats
+// a request with the same Idempotency-Key header. Keys are scoped per user
+// and their responses kept for ttl. A retry that arrives while the first
+// request is still running gets 409, a key reused with a different body gets
+// 422, and a key whose request failed with a server error or panicked is
+// released so it can be retried. Bodies of keyed requests larger than
+// maxIdempotentBodyBytes get 413. Requests without the header pass through
+// unchanged. Must run after JWTAuth.
+func Idempotency(st IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				apierr.Write(w, r, apierr.BadRequest("Idempotency-Key too long"))
+				return
+			}
+
+			userID, ok := UserIDFromContext(r.Context())
+			if !ok {
+				apierr.Write(w, r, apierr.Unauthorized("unauthorized"))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					apierr.Write(w, r, apierr.TooLarge("request body too large"))
+					return
+				}
+				apierr.Write(w, r, apierr.BadRequest("invalid request body"))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			hash := requestHash(r, body)
+
+			stored, reserved, err := st.ReserveIdempotencyKey(userID, key, hash, idempotencyLockTTL)
+			if err != nil {
+				apierr.Write(w, r, err)
+				return
+			}
+			if !reserved {
+				switch {
+				case stored.RequestHash != hash:
+					apierr.Write(w, r, apierr.Unprocessable("Idempotency-Key reused with a different request"))
+				case stored.StatusCode == 0:
+					apierr.Write(w, r, apierr.Conflict("a request with this Idempotency-Key is in progress"))
+				default:
+					if stored.ContentType != "" {
+						w.Header().Set("Content-Type", stored.ContentType)
+					}
+					w.Header().Set("Idempotent-Replayed", "true")
+					w.WriteHeader(stored.StatusCode)
+					w.Write(stored.Body)
+				}
+				return
+			}
+
+			// Free the key unless the response is stored, also when the
+			// handler panics
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := st.ReleaseIdempotencyKey(userID, key, hash); err != nil {
+					logg.ErrorContext(r.Context(), "idempotency", "Failed to release Idempotency-Key", err)
+				}
+			}()
+
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= http.StatusInternalServerError {
+				return
+			}
+			// The request took effect, so the key is not released even if
+			// the response cannot be stored: an immediate retry would repeat
+			// it. The reservation expires after idempotencyLockTTL instead.
+			completed = true
+			if err := st.CompleteIdempotencyKey(userID, key, models.IdempotentResponse{
+				RequestHash: hash,
+				StatusCode:  rec.status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			}, ttl); err != nil {
+				logg.ErrorContext(r.Context(), "idempotency", "Failed to store idempotent response", err)
+			}
+		})
+	}
+}
+
+// requestHash fingerprints a request, so a key cannot be reused for a different one.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+	"example.com/cassandrafeed/internal/store"
+)
+
+// failingComplete is a store whose responses cannot be stored.
+type failingComplete struct {
+	*store.MockStore
+}
+
+func (f failingComplete) CompleteIdempotencyKey(string, string, models.IdempotentResponse, time.Duration) error {
+	return errors.New("complete failed")
+}
+
+// countingHandler answers with status and counts its calls.
+func countingHandler(status int, calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"ok":true}`))
+	})
+}
+
+func doIdempotent(t *testing.T, h http.Handler, key, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/posts", strings.NewReader(body))
+	req.Header.Set(IdempotencyHeader, key)
+	req = req.WithContext(context.WithValue(req.Context(), UserCtxKey, "user-1"))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotency_ReplaysStoredResponse(t *testing.T) {
+	calls := 0
+	h := Idempotency(store.NewMock(), time.Hour)(countingHandler(http.StatusCreated, &calls))
+
+	first := doIdempotent(t, h, "k1", `{"body":"hi"}`)
+	second := doIdempotent(t, h, "k1", `{"body":"hi"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %q, want %d %q", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay is missing the Idempotent-Replayed header")
+	}
+}
+
+func TestIdempotency_RejectsDifferentRequest(t *testing.T) {
+	calls := 0
+	h := Idempotency(store.NewMock(), time.Hour)(countingHandler(http.StatusCreated, &calls))
+
+	doIdempotent(t, h, "k1", `{"body":"hi"}`)
+	if rec := doIdempotent(t, h, "k1", `{"body":"other"}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotency_InProgressConflict(t *testing.T) {
+	st := store.NewMock()
+	if _, _, err := st.ReserveIdempotencyKey("user-1", "k1", requestHash(httptest.NewRequest(http.MethodPost, "/posts", nil), []byte("{}")), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	h := Idempotency(st, time.Hour)(countingHandler(http.StatusCreated, &calls))
+
+	if rec := doIdempotent(t, h, "k1", "{}"); rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if calls != 0 {
+		t.Errorf("handler called %d times, want 0", calls)
+	}
+}
+
+func TestIdempotency_ServerErrorReleasesKey(t *testing.T) {
+	calls := 0
+	h := Idempotency(store.NewMock(), time.Hour)(countingHandler(http.StatusInternalServerError, &calls))
+
+	doIdempotent(t, h, "k1", "{}")
+	doIdempotent(t, h, "k1", "{}")
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestIdempotency_PanicReleasesKey(t *testing.T) {
+	st := store.NewMock()
+	panicking := Idempotency(st, time.Hour)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("panic was swallowed")
+			}
+		}()
+		doIdempotent(t, panicking, "k1", "{}")
+	}()
+
+	calls := 0
+	h := Idempotency(st, time.Hour)(countingHandler(http.StatusCreated, &calls))
+	if rec := doIdempotent(t, h, "k1", "{}"); rec.Code != http.StatusCreated || calls != 1 {
+		t.Errorf("retry after panic = %d with %d calls, want %d with 1", rec.Code, calls, http.StatusCreated)
+	}
+}
+
+func TestIdempotency_FailedCompleteKeepsKeyLocked(t *testing.T) {
+	st := failingComplete{store.NewMock()}
+	calls := 0
+	h := Idempotency(st, time.Hour)(countingHandler(http.StatusCreated, &calls))
+
+	if rec := doIdempotent(t, h, "k1", "{}"); rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	// The request took effect, so a retry must not run it again.
+	if rec := doIdempotent(t, h, "k1", "{}"); rec.Code != http.StatusConflict {
+		t.Errorf("retry status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestIdempotency_RejectsLargeBody(t *testing.T) {
+	calls := 0
+	h := Idempotency(store.NewMock(), time.Hour)(countingHandler(http.StatusCreated, &calls))
+
+	rec := doIdempotent(t, h, "k1", strings.Repeat("x", maxIdempotentBodyBytes+1))
+	if rec.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Fatalf("status = %d with %d handler calls, want 413 and none", rec.Code, calls)
+	}
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"example.com/cassandrafeed/internal/metrics"
+)
+
+// Metrics records the count and latency of requests by route. It must wrap
+// the ServeMux directly: the route is the pattern the mux matched, which it
+// sets on the request it is given. Requests matching no route are recorded as
+// "unmatched" and non-standard methods as "OTHER", so probing random paths or
+// methods cannot grow the label set.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		metrics.ObserveHTTP(route(r), method(r), sw.status, time.Since(start))
+	})
+}
+
+// route returns the path of the pattern the mux matched for r, e.g.
+// "/v1/users/{id}", or "unmatched".
+func route(r *http.Request) string {
+	if r.Pattern == "" {
+		return "unmatched"
+	}
+	// Patterns are "METHOD /path"; the method is recorded on its own.
+	if _, path, found := strings.Cut(r.Pattern, " "); found {
+		return path
+	}
+	return r.Pattern
+}
+
+// method returns the method of r if it is a standard one, or "OTHER".
+func method(r *http.Request) string {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return r.Method
+	}
+	return "OTHER"
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
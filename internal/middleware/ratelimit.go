@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"example.com/cassandrafeed/internal/apierr"
+	config "example.com/cassandrafeed/internal/init"
+)
+
+// RateDecision is the outcome of taking one token from a bucket.
+type RateDecision struct {
+	Allowed    bool
+	Remaining  int           // tokens left after this request
+	RetryAfter time.Duration // until the next token, if not allowed
+	Reset      time.Duration // until the bucket is full again
+}
+
+// RateLimiter keeps token buckets. MemoryLimiter keeps them per process;
+// a shared implementation lets several server instances enforce one limit.
+type RateLimiter interface {
+	Take(key string, limit config.RateLimit) (RateDecision, error)
+}
+
+// RateLimit limits requests to route per caller: the authenticated user if
+// JWTAuth ran before it, the client IP recorded by ClientIP otherwise. Rejected requests get 429
+// with Retry-After; every response carries RateLimit-* headers. If the
+// limiter fails the request is let through, so an outage of a shared
+// backend does not take the API down with it.
+func RateLimit(limiter RateLimiter, route string, limit config.RateLimit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit.Requests <= 0 || limit.Per <= 0 {
+			return next
+		}
+		policy := strconv.Itoa(limit.Requests) + ";w=" + strconv.Itoa(int(math.Ceil(limit.Per.Seconds())))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			d, err := limiter.Take(route+"|"+rateLimitKey(r), limit)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", policy)
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+			if !d.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(d.RetryAfter)))
+				apierr.Write(w, r, apierr.TooManyRequests("rate limit exceeded, retry later"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitKey identifies the caller of r.
+func rateLimitKey(r *http.Request) string {
+	if userID, ok := UserIDFromContext(r.Context()); ok {
+		return "user:" + userID
+	}
+	if ip, ok := ClientIPFromContext(r.Context()); ok {
+		return "ip:" + ip
+	}
+	return "ip:" + remoteHost(r)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// memorySweepInterval is how often MemoryLimiter drops buckets that have
+// refilled completely, which behave exactly like missing ones.
+const memorySweepInterval = time.Minute
+
+// MemoryLimiter is an in-process RateLimiter.
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket is full again
+}
+
+// NewMemoryLimiter creates an empty in-process limiter.
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take removes one token from the bucket of key, if there is one.
+func (m *MemoryLimiter) Take(key string, limit config.RateLimit) (RateDecision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) >= memorySweepInterval {
+		for k, b := range m.buckets {
+			if !now.Before(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	capacity := float64(limit.Requests)
+	perToken := limit.Per / time.Duration(limit.Requests)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.updated))/float64(perToken))
+	b.updated = now
+
+	d := RateDecision{Allowed: b.tokens >= 1}
+	if d.Allowed {
+		b.tokens--
+	} else {
+		d.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = time.Duration((capacity - b.tokens) * float64(perToken))
+	b.full = now.Add(d.Reset)
+	return d, nil
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"example.com/cassandrafeed/internal/requestid"
+)
+
+// maxRequestIDLen bounds request IDs accepted from clients.
+const maxRequestIDLen = 128
+
+// RequestID tags every request with an ID, reusing the client's X-Request-ID
+// header when it is present, and echoes it in the response header.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}
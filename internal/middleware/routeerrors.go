@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+
+	"example.com/cassandrafeed/internal/apierr"
+)
+
+// RouteErrors answers requests the mux has no route for with the same JSON
+// error body as every other error: 404 for unknown paths and 405, with the
+// mux's Allow header, for unsupported methods on known paths.
+func RouteErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Let the mux decide between 404 and 405, but drop its plain text body
+		rec := &headerRecorder{header: make(http.Header)}
+		mux.ServeHTTP(rec, r)
+
+		if rec.status == http.StatusMethodNotAllowed {
+			if allow := rec.header.Get("Allow"); allow != "" {
+				w.Header().Set("Allow", allow)
+			}
+			apierr.Write(w, r, apierr.MethodNotAllowed("method not allowed"))
+			return
+		}
+		apierr.Write(w, r, apierr.NotFound("no such route"))
+	})
+}
+
+// headerRecorder keeps the headers and status of a response and discards
+// its body.
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (h *headerRecorder) Header() http.Header { return h.header }
+
+func (h *headerRecorder) WriteHeader(status int) {
+	if h.status == 0 {
+		h.status = status
+	}
+}
+
+func (h *headerRecorder) Write(b []byte) (int, error) {
+	if h.status == 0 {
+		h.status = http.StatusOK
+	}
+	return len(b), nil
+}
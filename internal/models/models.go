@@ -7,6 +7,12 @@ type User struct {
 	Username string `json:"username"`
 }
 
+// Credentials are what a user logs in with. PasswordHash is a bcrypt hash.
+type Credentials struct {
+	UserID       string
+	PasswordHash string
+}
+
 type Post struct {
 	ID       string    `json:"id"`
 	AuthorID string    `json:"author_id"`
@@ -18,3 +24,38 @@ type Follow struct {
 	UserID     string `json:"user_id"`
 	FolloweeID string `json:"followee_id"`
 }
+
+// FollowCounts are the sizes of a user's follower and following lists.
+type FollowCounts struct {
+	Followers int64 `json:"followers"`
+	Following int64 `json:"following"`
+}
+
+// OutboxEvent is a pending Kafka message stored next to the data it describes.
+type OutboxEvent struct {
+	ID      string    `json:"id"`
+	Shard   int       `json:"shard"`
+	Key     string    `json:"key"`
+	Payload []byte    `json:"payload"`
+	Created time.Time `json:"created"`
+
+	// TraceContext carries the trace of the request that queued the event
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
+
+// Session is one login of a user. Its refresh token and the access tokens
+// issued for it carry its ID, so they can be revoked together.
+type Session struct {
+	ID     string
+	UserID string
+}
+
+// IdempotentResponse is the response stored for an Idempotency-Key and
+// replayed when a client retries the same request. StatusCode is 0 while the
+// original request is still in flight.
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
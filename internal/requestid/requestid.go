@@ -0,0 +1,28 @@
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID between clients, the server and its logs.
+const Header = "X-Request-ID"
+
+type contextKey struct{}
+
+// New generates a fresh request ID.
+func New() string {
+	return uuid.NewString()
+}
+
+// WithID returns a copy of ctx carrying the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID stored in ctx, or "" if there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
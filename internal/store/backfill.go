@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// backfillLogEvery is the number of copied rows between progress lines.
+const backfillLogEvery = 10000
+
+// BackfillFeeds copies the rows of the legacy feed table into feedTable and
+// returns how many it copied. Every copy keeps the write time of its source
+// row, so changes and deletions the services made in feedTable in the
+// meantime win over it, and the backfill can simply be run again after an
+// interruption. Other implementations, such as mocks, have nothing to copy.
+func BackfillFeeds(ctx context.Context, st StoreInterface) (int, error) {
+	s, ok := st.(*Store)
+	if !ok {
+		return 0, nil
+	}
+
+	iter := s.Session.Query(
+		`SELECT user_id, created_at, post_id, author_id, body, WRITETIME(body) FROM ` + legacyFeedTable,
+	).WithContext(ctx).PageSize(feedScanPageSize).Iter()
+
+	var userID, postID, authorID, body string
+	var created time.Time
+	var writetime int64
+	copied := 0
+	for iter.Scan(&userID, &created, &postID, &authorID, &body, &writetime) {
+		if err := s.Session.Query(`
+			INSERT INTO `+feedTable+` (user_id, post_id, author_id, body, created_at)
+			VALUES (?, ?, ?, ?, ?) USING TIMESTAMP ?`,
+			userID, postID, authorID, body, created, writetime,
+		).WithContext(ctx).Exec(); err != nil {
+			iter.Close()
+			logg.ErrorContext(ctx, "store", "Failed to copy feed row", err, "copied", copied)
+			return copied, err
+		}
+		copied++
+		if copied%backfillLogEvery == 0 {
+			logg.InfoContext(ctx, "store", "Feed backfill in progress", "copied", copied)
+		}
+	}
+	if err := iter.Close(); err != nil {
+		logg.ErrorContext(ctx, "store", "Failed to scan legacy feed table", err, "copied", copied)
+		return copied, err
+	}
+
+	logg.InfoContext(ctx, "store", "Feed backfill finished", "copied", copied)
+	return copied, nil
+}
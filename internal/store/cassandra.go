@@ -1,12 +1,15 @@
 package store
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
+	"time"
 
 	config "example.com/cassandrafeed/internal/init"
 	"example.com/cassandrafeed/internal/logger"
 	"example.com/cassandrafeed/internal/models"
+	"example.com/cassandrafeed/internal/ttlcache"
 	"github.com/gocql/gocql"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/cassandra"
@@ -25,13 +28,46 @@ type SessionInterface interface {
 }
 
 type StoreInterface interface {
-	CreateUser(username string) (string, error)
+	CreateUser(username, passwordHash string) (string, error)
 	CreateFollow(userId, followeeId string) error
+	DeleteFollowWithOutbox(userId, followeeId string, event models.OutboxEvent) error
 	GetFollowers(userId string) ([]string, error)
+	GetFollowersPage(userId string, limit int, cursor string) ([]string, string, error)
+	GetFollowingPage(userId string, limit int, cursor string) ([]string, string, error)
+	GetFollowCounts(userId string) (models.FollowCounts, error)
+	IsCelebrity(authorId string) (bool, error)
 	GetUserIDByUsername(username string) (string, error)
+	GetCredentials(username string) (models.Credentials, error)
+	GetUser(userId string) (models.User, error)
 	AddPost(post models.Post) error
+	GetPost(postId string) (models.Post, error)
+	GetAuthorPostsPage(authorId string, limit int, cursor string) ([]models.Post, string, error)
 	AddToFeed(userId string, post models.Post) error
+	AddToFeeds(userIds []string, post models.Post) error
+	UpdateInFeeds(userIds []string, post models.Post) error
+	RemoveFromFeed(userId string, post models.Post) error
+	RemoveAuthorFromFeed(userId, authorId string) error
 	GetFeed(userId string, limit int) ([]models.Post, error)
+	GetFeedPage(userId string, limit int, cursor string) ([]models.Post, string, error)
+	AddPostWithOutbox(post models.Post, event models.OutboxEvent) error
+	UpdatePostWithOutbox(post models.Post, event models.OutboxEvent) error
+	DeletePostWithOutbox(post models.Post, event models.OutboxEvent) error
+	GetPendingOutbox(shard int, since time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkOutboxDelivered(event models.OutboxEvent) error
+	GetOutboxCursor(shard int) (time.Time, error)
+	SaveOutboxCursor(shard int, deliveredUntil time.Time) error
+	IsEventProcessed(eventId string) (bool, error)
+	MarkEventProcessed(eventId string, ttl time.Duration) error
+	ReserveIdempotencyKey(userId, key, requestHash string, lockTTL time.Duration) (models.IdempotentResponse, bool, error)
+	CompleteIdempotencyKey(userId, key string, resp models.IdempotentResponse, ttl time.Duration) error
+	ReleaseIdempotencyKey(userId, key, requestHash string) error
+	CreateSession(session models.Session, tokenHash string, ttl time.Duration) error
+	RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (models.Session, error)
+	RevokeSession(userId, sessionId string) error
+	RevokeAllSessions(userId string) ([]string, error)
+	RevokeToken(tokenId string, ttl time.Duration) error
+	IsTokenRevoked(tokenId string) (bool, error)
+	Ping(ctx context.Context) error
 	Close()
 }
 
@@ -39,6 +75,14 @@ type StoreInterface interface {
 
 type Store struct {
 	Session SessionInterface
+
+	// CelebrityThreshold is the follower count from which an author's posts
+	// are merged into feeds on read instead of fanned out (0 disables).
+	CelebrityThreshold int64
+
+	// followees caches the celebrity followees of users for GetFeedPage;
+	// nil caches nothing.
+	followees *ttlcache.Cache[string, []string]
 }
 
 // New initializes Cassandra connection using config package.
@@ -58,6 +102,13 @@ func New() (StoreInterface, error) {
 	cluster.Consistency = gocql.Quorum
 	cluster.Timeout = cfg.CassandraTimeout
 	cluster.ConnectTimeout = cfg.CassandraTimeout
+	// Route every query to a replica of its partition, so feed fan-out
+	// inserts skip the extra coordinator hop
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	// Record the latency of every query and batch, and trace those run
+	// on behalf of a traced request or message
+	cluster.QueryObserver = queryObserver{}
+	cluster.BatchObserver = queryObserver{}
 
 	if cfg.CassandraUsername != "" && cfg.CassandraPassword != "" {
 		cluster.Authenticator = gocql.PasswordAuthenticator{
@@ -76,7 +127,11 @@ func New() (StoreInterface, error) {
 	}
 
 	logg.Info("store", "Connected to Cassandra keyspace (host anonymized)")
-	return &Store{Session: sess}, nil
+	return &Store{
+		Session:            sess,
+		CelebrityThreshold: int64(cfg.CelebrityFollowerThreshold),
+		followees:          ttlcache.New[string, []string](maxFolloweeCacheEntries),
+	}, nil
 }
 
 // --- Ensure keyspace exists before migrations ---
@@ -131,6 +186,51 @@ func runMigrations(cfg *config.Config) error {
 	return nil
 }
 
+// WithTrace returns st with its Cassandra queries traced as children of the
+// span in ctx and its log lines tagged with the request and trace IDs of ctx.
+// Cancelling ctx does not cancel the queries: a fan-out interrupted halfway
+// would only be redelivered and repeated. Other implementations, such as
+// mocks, are returned unchanged.
+func WithTrace(st StoreInterface, ctx context.Context) StoreInterface {
+	s, ok := st.(*Store)
+	if !ok {
+		return st
+	}
+	ctx = context.WithoutCancel(ctx)
+	traced := *s
+	traced.Session = tracedSession{SessionInterface: s.Session, ctx: ctx}
+	return &traced
+}
+
+// logCtx is the context the store's log lines are tagged from: the one given
+// to WithTrace, or none for an untraced store.
+func (s *Store) logCtx() context.Context {
+	if traced, ok := s.Session.(tracedSession); ok {
+		return traced.ctx
+	}
+	return context.Background()
+}
+
+// tracedSession attaches a context to every query and batch it creates.
+type tracedSession struct {
+	SessionInterface
+	ctx context.Context
+}
+
+func (s tracedSession) Query(stmt string, values ...interface{}) *gocql.Query {
+	return s.SessionInterface.Query(stmt, values...).WithContext(s.ctx)
+}
+
+func (s tracedSession) NewBatch(batchType gocql.BatchType) *gocql.Batch {
+	return s.SessionInterface.NewBatch(batchType).WithContext(s.ctx)
+}
+
+// Ping runs a cheap query against the local node to check that the session
+// can still reach Cassandra.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.Session.Query(`SELECT release_version FROM system.local`).WithContext(ctx).Exec()
+}
+
 // Close gracefully closes Cassandra session.
 func (s *Store) Close() {
 	if s.Session != nil {
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// ErrInvalidCursor is returned when a client supplies a malformed page cursor.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// feedPosition is the clustering key of the last post on a feed page.
+// The next page continues with posts strictly older than it.
+type feedPosition struct {
+	Created time.Time
+	PostID  string
+}
+
+// newer reports whether p sorts before other in a newest-first feed.
+func (p feedPosition) newer(other feedPosition) bool {
+	if !p.Created.Equal(other.Created) {
+		return p.Created.After(other.Created)
+	}
+	return p.PostID > other.PostID
+}
+
+// encodeCursor turns raw cursor state into an opaque, URL-safe cursor.
+// Empty state means there are no more pages and yields "".
+func encodeCursor(state []byte) string {
+	if len(state) == 0 {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(state)
+}
+
+// decodeCursor reverses encodeCursor. An empty cursor selects the first page.
+func decodeCursor(cursor string) ([]byte, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	state, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(state) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return state, nil
+}
+
+// encodePosition turns a feed position into a cursor; nil yields "".
+func encodePosition(pos *feedPosition) string {
+	if pos == nil {
+		return ""
+	}
+	return encodeCursor([]byte(strconv.FormatInt(pos.Created.UnixMilli(), 10) + ":" + pos.PostID))
+}
+
+// decodePosition reverses encodePosition. An empty cursor yields nil; a post
+// ID that is not a UUID, which Cassandra could not compare, is invalid.
+func decodePosition(cursor string) (*feedPosition, error) {
+	state, err := decodeCursor(cursor)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	millis, postID, ok := strings.Cut(string(state), ":")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := gocql.ParseUUID(postID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	ms, err := strconv.ParseInt(millis, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &feedPosition{Created: time.UnixMilli(ms).UTC(), PostID: postID}, nil
+}
@@ -0,0 +1,43 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPositionCursorRoundTrip(t *testing.T) {
+	pos := &feedPosition{Created: time.UnixMilli(1767225600123).UTC(), PostID: "9b2c1c52-5e0e-4a39-9a57-2b0d7b3f1a10"}
+
+	got, err := decodePosition(encodePosition(pos))
+	if err != nil {
+		t.Fatalf("decodePosition failed: %v", err)
+	}
+	if got == nil || !got.Created.Equal(pos.Created) || got.PostID != pos.PostID {
+		t.Errorf("round trip = %+v, want %+v", got, pos)
+	}
+}
+
+func TestDecodePosition_Empty(t *testing.T) {
+	if encodePosition(nil) != "" {
+		t.Error("a nil position must encode to an empty cursor")
+	}
+	pos, err := decodePosition("")
+	if pos != nil || err != nil {
+		t.Errorf("decodePosition(\"\") = %v, %v, want nil, nil", pos, err)
+	}
+}
+
+func TestDecodePosition_Invalid(t *testing.T) {
+	for _, cursor := range []string{
+		"not base64!",
+		encodeCursor([]byte("no-separator")),
+		encodeCursor([]byte("123:")),
+		encodeCursor([]byte("abc:9b2c1c52-5e0e-4a39-9a57-2b0d7b3f1a10")),
+		encodeCursor([]byte("123:not-a-uuid")),
+	} {
+		if _, err := decodePosition(cursor); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("decodePosition(%q) error = %v, want ErrInvalidCursor", cursor, err)
+		}
+	}
+}
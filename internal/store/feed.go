@@ -1,12 +1,20 @@
 package store
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"example.com/cassandrafeed/internal/models"
 	"github.com/gocql/gocql"
+	"github.com/google/uuid"
 )
 
+// feedLogg samples the per-call lines of fan-out and feed reads, which run
+// once per follower or request.
+var feedLogg = logg.Sampled(10, 100)
+
 // --- User operations ---
 
 // GetUserIDByUsername returns the existing user_id by username.
@@ -21,74 +29,273 @@ func (s *Store) GetUserIDByUsername(username string) (string, error) {
 		if err == gocql.ErrNotFound {
 			return "", nil
 		}
-		logg.Error("store", "Failed to query user by username", err)
+		logg.ErrorContext(s.logCtx(), "store", "Failed to query user by username", err)
 		return "", err
 	}
 	return id, nil
 }
 
-// CreateUser creates a new user if the username does not exist.
-// Returns the existing user_id if username already exists.
-func (s *Store) CreateUser(username string) (string, error) {
-	existingID, err := s.GetUserIDByUsername(username)
-	if err != nil {
-		return "", err
-	}
-	if existingID != "" {
-		return existingID, nil
-	}
+// ErrUsernameTaken is returned by CreateUser when the username is already registered.
+var ErrUsernameTaken = errors.New("username already taken")
 
+// CreateUser registers a new user with the given password hash.
+// Returns ErrUsernameTaken if the username already exists.
+func (s *Store) CreateUser(username, passwordHash string) (string, error) {
 	// Generate a new UUID for user_id
 	id := gocql.TimeUUID().String()
 
-	// Insert into users_by_username table using CAS
+	// Insert into main users table first, so a claimed username always
+	// belongs to an existing user
+	err := s.Session.Query(`
+		INSERT INTO users (user_id, username)
+		VALUES (?, ?)`,
+		id, username,
+	).Exec()
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to create user in main table", err)
+		return "", err
+	}
+
+	// Claim the username using CAS, so concurrent registrations cannot both win
 	result := make(map[string]interface{})
 	applied, err := s.Session.Query(`
-		INSERT INTO users_by_username (username, user_id)
-		VALUES (?, ?) IF NOT EXISTS`,
-		username, id,
+		INSERT INTO users_by_username (username, user_id, password_hash)
+		VALUES (?, ?, ?) IF NOT EXISTS`,
+		username, id, passwordHash,
 	).MapScanCAS(result)
 	if err != nil {
-		logg.Error("store", "Failed to create username entry", err)
+		logg.ErrorContext(s.logCtx(), "store", "Failed to create username entry", err)
+		// The claim may have been applied even though it failed, e.g. on a
+		// timeout. Undo it, but only if it is ours.
+		s.unclaimUsername(username, id)
+		s.deleteUnclaimedUser(id)
 		return "", err
 	}
 
 	if !applied {
-		// Another process already created this user
-		return s.GetUserIDByUsername(username)
+		s.deleteUnclaimedUser(id)
+		return "", ErrUsernameTaken
 	}
 
-	// Insert into main users table
-	err = s.Session.Query(`
-		INSERT INTO users (user_id, username)
-		VALUES (?, ?)`,
-		id, username,
-	).Exec()
+	logg.DebugContext(s.logCtx(), "store", "User created successfully (username anonymized)")
+	return id, nil
+}
+
+// unclaimUsername removes a username claim left by a failed registration.
+// It is conditional, so a claim of another user is never removed.
+func (s *Store) unclaimUsername(username, userID string) {
+	_, err := s.Session.Query(
+		`DELETE FROM users_by_username WHERE username = ? IF user_id = ?`,
+		username, userID,
+	).MapScanCAS(make(map[string]interface{}))
 	if err != nil {
-		logg.Error("store", "Failed to create user in main table", err)
-		return "", err
+		logg.ErrorContext(s.logCtx(), "store", "Failed to remove username claim of failed registration", err)
 	}
+}
 
-	logg.Info("store", "User created successfully (username anonymized)")
-	return id, nil
+// deleteUnclaimedUser removes the users row of a registration that did not
+// get its username.
+func (s *Store) deleteUnclaimedUser(userID string) {
+	if err := s.Session.Query(`DELETE FROM users WHERE user_id = ?`, userID).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to remove user of failed registration", err)
+	}
+}
+
+// GetCredentials returns the login credentials of a user by username, or
+// gocql.ErrNotFound if the username is not registered.
+func (s *Store) GetCredentials(username string) (models.Credentials, error) {
+	var creds models.Credentials
+	err := s.Session.Query(
+		`SELECT user_id, password_hash FROM users_by_username WHERE username = ?`,
+		username,
+	).Scan(&creds.UserID, &creds.PasswordHash)
+	if err != nil {
+		if err != gocql.ErrNotFound {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to get credentials", err)
+		}
+		return models.Credentials{}, err
+	}
+	return creds, nil
+}
+
+// GetUser returns a user by ID, or gocql.ErrNotFound if it does not exist.
+func (s *Store) GetUser(userID string) (models.User, error) {
+	var user models.User
+	err := s.Session.Query(
+		`SELECT user_id, username FROM users WHERE user_id = ?`,
+		userID,
+	).Scan(&user.ID, &user.Username)
+	if err != nil {
+		if err != gocql.ErrNotFound {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to get user", err)
+		}
+		return models.User{}, err
+	}
+	return user, nil
 }
 
 // --- Follow operations ---
 
+// ErrAlreadyFollowing is returned by CreateFollow when the follow already exists.
+var ErrAlreadyFollowing = errors.New("already following")
+
+// CreateFollow stores the follow relationship in both follow tables and bumps
+// the follow counts of both users. Following someone twice returns
+// ErrAlreadyFollowing and leaves the counts untouched.
 func (s *Store) CreateFollow(userID, followeeID string) error {
+	// The follows row decides who wins a race of concurrent follows; the
+	// reverse index and the counts are only written by the winner.
+	applied, err := s.Session.Query(
+		`INSERT INTO follows (user_id, followee_id) VALUES (?, ?) IF NOT EXISTS`,
+		userID, followeeID,
+	).MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to create follow relationship", err)
+		return err
+	}
+	if !applied {
+		return ErrAlreadyFollowing
+	}
+	s.followees.Delete(userID)
+
+	if err := s.Session.Query(
+		`INSERT INTO followers_by_followee (followee_id, user_id) VALUES (?, ?)`,
+		followeeID, userID,
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to add follower to followee", err)
+		// Undo the follow, so a retry does not run into ErrAlreadyFollowing
+		if _, undoErr := s.Session.Query(
+			`DELETE FROM follows WHERE user_id = ? AND followee_id = ? IF EXISTS`,
+			userID, followeeID,
+		).MapScanCAS(make(map[string]interface{})); undoErr != nil {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to undo incomplete follow", undoErr)
+		}
+		return err
+	}
+	s.adjustFollowCounts(userID, followeeID, 1)
+
+	logg.DebugContext(s.logCtx(), "store", "Follow relationship created (user IDs anonymized)")
+	return nil
+}
+
+// DeleteFollowWithOutbox removes the follow relationship and queues its
+// outbox event in the same logged batch. The counts are only lowered if the
+// relationship existed. A failed call can be retried.
+func (s *Store) DeleteFollowWithOutbox(userID, followeeID string, event models.OutboxEvent) error {
+	applied, err := s.Session.Query(
+		`DELETE FROM follows WHERE user_id = ? AND followee_id = ? IF EXISTS`,
+		userID, followeeID,
+	).MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to delete follow relationship", err)
+		return err
+	}
+	if applied {
+		s.followees.Delete(userID)
+		s.adjustFollowCounts(userID, followeeID, -1)
+	}
+
+	// Runs even if the follow was already gone, so a retry after a failure
+	// here still cleans up the reverse index and the feed
 	batch := s.Session.NewBatch(gocql.LoggedBatch)
-	batch.Query(`INSERT INTO follows (user_id, followee_id) VALUES (?, ?)`, userID, followeeID)
-	batch.Query(`INSERT INTO followers_by_followee (followee_id, user_id) VALUES (?, ?)`, followeeID, userID)
+	batch.Query(`DELETE FROM followers_by_followee WHERE followee_id = ? AND user_id = ?`, followeeID, userID)
+	addOutboxInsert(batch, prepareOutboxEvent(event, userID))
 
 	if err := s.Session.ExecuteBatch(batch); err != nil {
-		logg.Error("store", "Failed to create follow relationship", err)
+		logg.ErrorContext(s.logCtx(), "store", "Failed to delete follower with outbox event", err)
 		return err
 	}
 
-	logg.Info("store", "Follow relationship created (user IDs anonymized)")
+	logg.DebugContext(s.logCtx(), "store", "Follow relationship deleted and outbox event stored (user IDs anonymized)")
 	return nil
 }
 
+// adjustFollowCounts adds delta to the follower's following count and the
+// followee's follower count. Counter updates cannot share a batch with regular
+// writes, so they go out in their own counter batch. A failure is only
+// logged: the relationship is already stored, and retrying a counter update
+// whose outcome is unknown could count it twice.
+func (s *Store) adjustFollowCounts(userID, followeeID string, delta int64) {
+	batch := s.Session.NewBatch(gocql.CounterBatch)
+	batch.Query(`UPDATE follower_counts SET followers = followers + ? WHERE user_id = ?`, delta, followeeID)
+	batch.Query(`UPDATE follower_counts SET following = following + ? WHERE user_id = ?`, delta, userID)
+
+	if err := s.Session.ExecuteBatch(batch); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to update follow counts, they may be off by one", err, "delta", delta)
+	}
+}
+
+// GetFollowCounts returns how many followers the user has and how many users
+// they follow.
+func (s *Store) GetFollowCounts(userID string) (models.FollowCounts, error) {
+	var counts models.FollowCounts
+	err := s.Session.Query(
+		`SELECT followers, following FROM follower_counts WHERE user_id = ?`,
+		userID,
+	).Scan(&counts.Followers, &counts.Following)
+	if err != nil {
+		if err == gocql.ErrNotFound {
+			return models.FollowCounts{}, nil
+		}
+		logg.ErrorContext(s.logCtx(), "store", "Failed to get follow counts", err)
+		return models.FollowCounts{}, err
+	}
+	return counts, nil
+}
+
+// GetFollowersPage returns one page of the IDs following userID together with
+// the cursor for the next page ("" when there are no more).
+func (s *Store) GetFollowersPage(userID string, limit int, cursor string) ([]string, string, error) {
+	return s.readFollowPage("followers_by_followee", "followee_id", "user_id", userID, limit, cursor)
+}
+
+// GetFollowingPage returns one page of the IDs userID follows together with
+// the cursor for the next page ("" when there are no more).
+func (s *Store) GetFollowingPage(userID string, limit int, cursor string) ([]string, string, error) {
+	return s.readFollowPage("follows", "user_id", "followee_id", userID, limit, cursor)
+}
+
+// readFollowPage pages through the clustering column of one follow table
+// partition. The cursor is the last ID returned.
+func (s *Store) readFollowPage(table, keyColumn, idColumn, key string, limit int, cursor string) ([]string, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if after != nil {
+		// The cursor is the last user ID of the previous page
+		if _, err := uuid.Parse(string(after)); err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+
+	stmt := fmt.Sprintf(`SELECT %s FROM %s WHERE %s = ?`, idColumn, table, keyColumn)
+	values := []interface{}{key}
+	if after != nil {
+		stmt += fmt.Sprintf(` AND %s > ?`, idColumn)
+		values = append(values, string(after))
+	}
+	stmt += ` LIMIT ?`
+	values = append(values, limit)
+
+	iter := s.Session.Query(stmt, values...).Iter()
+
+	var id string
+	var res []string
+	for iter.Scan(&id) {
+		res = append(res, id)
+	}
+
+	if err := iter.Close(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to read page", err, "table", table)
+		return nil, "", err
+	}
+	if len(res) < limit {
+		return res, "", nil
+	}
+	return res, encodeCursor([]byte(res[len(res)-1])), nil
+}
+
 func (s *Store) GetFollowers(userID string) ([]string, error) {
 	iter := s.Session.Query(
 		`SELECT user_id FROM followers_by_followee WHERE followee_id = ?`,
@@ -102,70 +309,300 @@ func (s *Store) GetFollowers(userID string) ([]string, error) {
 	}
 
 	if err := iter.Close(); err != nil {
-		logg.Error("store", "Failed to get followers", err)
+		logg.ErrorContext(s.logCtx(), "store", "Failed to get followers", err)
 		return nil, err
 	}
 
-	logg.Info("store", "Retrieved followers (user IDs anonymized)")
+	feedLogg.DebugContext(s.logCtx(), "store", "Retrieved followers (user IDs anonymized)")
 	return res, nil
 }
 
 // --- Post operations ---
 
 func (s *Store) AddPost(post models.Post) error {
-	if err := s.Session.Query(`
+	batch := s.Session.NewBatch(gocql.LoggedBatch)
+	addPostInsert(batch, post)
+
+	if err := s.Session.ExecuteBatch(batch); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to add post", err)
+		return err
+	}
+
+	logg.DebugContext(s.logCtx(), "store", "Post added to posts table (post content anonymized)")
+	return nil
+}
+
+// addPostInsert appends the insert of a post into posts and posts_by_author to a batch.
+func addPostInsert(batch *gocql.Batch, post models.Post) {
+	batch.Query(`
 		INSERT INTO posts (post_id, author_id, body, created_at)
 		VALUES (?, ?, ?, ?)`,
 		post.ID, post.AuthorID, post.Body, post.Created,
-	).Exec(); err != nil {
-		logg.Error("store", "Failed to add post", err)
-		return err
+	)
+	batch.Query(`
+		INSERT INTO posts_by_author (author_id, created_at, post_id, body)
+		VALUES (?, ?, ?, ?)`,
+		post.AuthorID, post.Created, post.ID, post.Body,
+	)
+}
+
+// GetPost returns a post by ID, or gocql.ErrNotFound if it does not exist.
+func (s *Store) GetPost(postID string) (models.Post, error) {
+	var post models.Post
+	err := s.Session.Query(
+		`SELECT post_id, author_id, body, created_at FROM posts WHERE post_id = ?`,
+		postID,
+	).Scan(&post.ID, &post.AuthorID, &post.Body, &post.Created)
+	if err != nil {
+		if err != gocql.ErrNotFound {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to get post", err)
+		}
+		return models.Post{}, err
 	}
+	return post, nil
+}
 
-	logg.Info("store", "Post added to posts table (post content anonymized)")
-	return nil
+// GetAuthorPostsPage returns one page of the posts written by authorID,
+// newest first, together with the cursor for the next page.
+func (s *Store) GetAuthorPostsPage(authorID string, limit int, cursor string) ([]models.Post, string, error) {
+	pos, err := decodePosition(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	page, err := s.readFeedSlice("posts_by_author", "author_id", authorID, limit, pos, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	feedLogg.DebugContext(s.logCtx(), "store", "Author posts page retrieved successfully (IDs and content anonymized)")
+	return page.posts, encodePosition(page.last), nil
 }
 
+// feedTable holds the materialized feeds. legacyFeedTable is its predecessor,
+// which clusters post_id ascending. Until it is dropped, changes to existing
+// feed rows are applied to both tables, so BackfillFeeds never copies a
+// stale or removed row into feedTable.
+const (
+	feedTable       = "feed_by_user_v2"
+	legacyFeedTable = "feed_by_user"
+)
+
 func (s *Store) AddToFeed(userID string, post models.Post) error {
 	if err := s.Session.Query(`
-		INSERT INTO feed_by_user (user_id, post_id, author_id, body, created_at)
+		INSERT INTO `+feedTable+` (user_id, post_id, author_id, body, created_at)
 		VALUES (?, ?, ?, ?, ?)`,
 		userID, post.ID, post.AuthorID, post.Body, post.Created,
 	).Exec(); err != nil {
-		logg.Error("store", "Failed to add post to feed", err)
+		logg.ErrorContext(s.logCtx(), "store", "Failed to add post to feed", err)
 		return err
 	}
 
-	logg.Info("store", "Post added to user's feed (IDs and content anonymized)")
+	feedLogg.DebugContext(s.logCtx(), "store", "Post added to user's feed (IDs and content anonymized)")
 	return nil
 }
 
-func (s *Store) GetFeed(userID string, limit int) ([]models.Post, error) {
-	iter := s.Session.Query(`
-		SELECT post_id, author_id, body, created_at
-		FROM feed_by_user WHERE user_id = ? LIMIT ?`,
-		userID, limit,
-	).Iter()
+// AddToFeeds inserts the post into the feed of every given user and returns
+// the first error. Each row lives in a different partition, so instead of a
+// multi-partition batch the inserts are sent concurrently and token-aware
+// routing delivers each one straight to a replica. Callers bound the number
+// of in-flight inserts through the size of userIDs.
+func (s *Store) AddToFeeds(userIDs []string, post models.Post) error {
+	err := forEachFeed(userIDs, func(userID string) error {
+		return s.Session.Query(`
+			INSERT INTO `+feedTable+` (user_id, post_id, author_id, body, created_at)
+			VALUES (?, ?, ?, ?, ?)`,
+			userID, post.ID, post.AuthorID, post.Body, post.Created,
+		).Exec()
+	})
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to add post to feeds", err)
+		return err
+	}
 
-	var res []models.Post
-	var pid, aid string
-	var body string
-	var created time.Time
+	feedLogg.DebugContext(s.logCtx(), "store", "Post added to feeds (IDs and content anonymized)", "feeds", len(userIDs))
+	return nil
+}
+
+// UpdateInFeeds rewrites the body of the post in the feeds of the given users
+// that still hold it. The rows are updated conditionally, so an update never
+// adds the post to a feed it was not fanned out to, such as that of a later
+// follower, nor brings it back after a deletion that overtook the update.
+func (s *Store) UpdateInFeeds(userIDs []string, post models.Post) error {
+	err := forEachFeed(userIDs, func(userID string) error {
+		for _, table := range []string{feedTable, legacyFeedTable} {
+			_, err := s.Session.Query(`
+				UPDATE `+table+` SET body = ?
+				WHERE user_id = ? AND created_at = ? AND post_id = ? IF EXISTS`,
+				post.Body, userID, post.Created, post.ID,
+			).MapScanCAS(make(map[string]interface{}))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to update post in feeds", err)
+		return err
+	}
+
+	feedLogg.DebugContext(s.logCtx(), "store", "Post updated in feeds (IDs and content anonymized)", "feeds", len(userIDs))
+	return nil
+}
+
+// forEachFeed runs fn concurrently for every user and returns the first error.
+func forEachFeed(userIDs []string, fn func(userID string) error) error {
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for _, uid := range userIDs {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			if err := fn(userID); err != nil {
+				errOnce.Do(func() { firstErr = err })
+			}
+		}(uid)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// RemoveFromFeed deletes a single post from the user's feed.
+func (s *Store) RemoveFromFeed(userID string, post models.Post) error {
+	for _, table := range []string{feedTable, legacyFeedTable} {
+		if err := s.Session.Query(
+			`DELETE FROM `+table+` WHERE user_id = ? AND created_at = ? AND post_id = ?`,
+			userID, post.Created, post.ID,
+		).Exec(); err != nil {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to remove post from feed", err, "table", table)
+			return err
+		}
+	}
+
+	feedLogg.DebugContext(s.logCtx(), "store", "Post removed from user's feed (IDs anonymized)")
+	return nil
+}
+
+// feedScanPageSize is the number of feed rows fetched per page when a
+// feed is scanned, and feedDeleteChunk the number of deletes sent per batch,
+// well below Cassandra's batch size limits.
+const (
+	feedScanPageSize = 500
+	feedDeleteChunk  = 100
+)
+
+// RemoveAuthorFromFeed deletes every post of authorID from the user's feed.
+// The feed is clustered by time, so the partition is scanned page by page for
+// matching rows, which are removed in bounded single-partition unlogged batches.
+func (s *Store) RemoveAuthorFromFeed(userID, authorID string) error {
+	for _, table := range []string{feedTable, legacyFeedTable} {
+		if err := s.removeAuthorFromTable(table, userID, authorID); err != nil {
+			return err
+		}
+	}
+
+	feedLogg.DebugContext(s.logCtx(), "store", "Author posts removed from user's feed (IDs anonymized)")
+	return nil
+}
+
+// removeAuthorFromTable deletes every post of authorID from the user's
+// partition of one feed table.
+func (s *Store) removeAuthorFromTable(table, userID, authorID string) error {
+	iter := s.Session.Query(
+		`SELECT created_at, post_id, author_id FROM `+table+` WHERE user_id = ?`,
+		userID,
+	).PageSize(feedScanPageSize).Iter()
+
+	batch := s.Session.NewBatch(gocql.UnloggedBatch)
+	flush := func() error {
+		if batch.Size() == 0 {
+			return nil
+		}
+		if err := s.Session.ExecuteBatch(batch); err != nil {
+			return err
+		}
+		batch = s.Session.NewBatch(gocql.UnloggedBatch)
+		return nil
+	}
 
-	for iter.Scan(&pid, &aid, &body, &created) {
-		res = append(res, models.Post{
-			ID:       pid,
-			AuthorID: aid,
-			Body:     body,
-			Created:  created,
-		})
+	var created time.Time
+	var pid, aid string
+	for iter.Scan(&created, &pid, &aid) {
+		if aid != authorID {
+			continue
+		}
+		batch.Query(
+			`DELETE FROM `+table+` WHERE user_id = ? AND created_at = ? AND post_id = ?`,
+			userID, created, pid,
+		)
+		if batch.Size() >= feedDeleteChunk {
+			if err := flush(); err != nil {
+				iter.Close()
+				logg.ErrorContext(s.logCtx(), "store", "Failed to remove author posts from feed", err, "table", table)
+				return err
+			}
+		}
 	}
 
 	if err := iter.Close(); err != nil {
-		logg.Error("store", "Failed to retrieve user feed", err)
-		return nil, err
+		logg.ErrorContext(s.logCtx(), "store", "Failed to scan feed for author removal", err, "table", table)
+		return err
+	}
+	if err := flush(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to remove author posts from feed", err, "table", table)
+		return err
 	}
+	return nil
+}
 
-	logg.Info("store", "User feed retrieved successfully (IDs and content anonymized)")
-	return res, nil
+// GetFeed returns the newest posts of the user's feed.
+func (s *Store) GetFeed(userID string, limit int) ([]models.Post, error) {
+	posts, _, err := s.GetFeedPage(userID, limit, "")
+	return posts, err
+}
+
+// GetFeedPage returns one page of the user's feed, newest first, together with
+// the cursor for the next page ("" when the feed is exhausted). The
+// materialized feed rows are merged with recent posts of followed
+// celebrities, which are read from posts_by_author instead of being fanned out.
+func (s *Store) GetFeedPage(userID string, limit int, cursor string) ([]models.Post, string, error) {
+	pos, err := decodePosition(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	celebrities, err := s.celebrityFollowees(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	// Rows fanned out before an author became a celebrity are served from
+	// posts_by_author as well, so their feed copies are skipped.
+	skip := make(map[string]bool, len(celebrities))
+	for _, id := range celebrities {
+		skip[id] = true
+	}
+
+	feed, err := s.readFeedSlice(feedTable, "user_id", userID, limit, pos, skip)
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to retrieve user feed page", err)
+		return nil, "", err
+	}
+	slices := []feedSlice{feed}
+
+	for _, authorID := range celebrities {
+		sl, err := s.readFeedSlice("posts_by_author", "author_id", authorID, limit, pos, nil)
+		if err != nil {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to retrieve celebrity posts for feed", err)
+			return nil, "", err
+		}
+		slices = append(slices, sl)
+	}
+
+	res, next := mergeFeed(limit, slices)
+
+	feedLogg.DebugContext(s.logCtx(), "store", "User feed page retrieved successfully (IDs and content anonymized)")
+	return res, encodePosition(next), nil
 }
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+)
+
+// Hybrid feed: posts of authors with at least CelebrityThreshold followers are
+// not fanned out into the feed table. Readers merge them in from posts_by_author
+// instead, so a single post never triggers millions of feed writes.
+
+// followerCountChunk caps the number of keys per IN query on follower_counts.
+const followerCountChunk = 100
+
+// celebrityFolloweesTTL is how long the celebrity followees of a user are
+// cached. Follows and unfollows through this instance apply at once; an
+// author crossing the threshold is picked up after at most this long.
+const celebrityFolloweesTTL = time.Minute
+
+// maxFolloweeCacheEntries bounds the cache of celebrity followees.
+const maxFolloweeCacheEntries = 100000
+
+// feedSlice is up to limit posts of one feed source past the cursor, newest first.
+type feedSlice struct {
+	posts []models.Post
+	last  *feedPosition // final row read if the source may hold more rows, nil once exhausted
+}
+
+// IsCelebrity reports whether posts of authorID are merged into feeds on read
+// rather than fanned out on write.
+func (s *Store) IsCelebrity(authorID string) (bool, error) {
+	if s.CelebrityThreshold <= 0 {
+		return false, nil
+	}
+	counts, err := s.GetFollowCounts(authorID)
+	if err != nil {
+		return false, err
+	}
+	return counts.Followers >= s.CelebrityThreshold, nil
+}
+
+// celebrityFollowees returns the authors followed by userID whose posts are
+// not fanned out.
+func (s *Store) celebrityFollowees(userID string) ([]string, error) {
+	if s.CelebrityThreshold <= 0 {
+		return nil, nil
+	}
+	if ids, ok := s.followees.Get(userID); ok {
+		return ids, nil
+	}
+
+	iter := s.Session.Query(`SELECT followee_id FROM follows WHERE user_id = ?`, userID).Iter()
+	var id string
+	var followees []string
+	for iter.Scan(&id) {
+		followees = append(followees, id)
+	}
+	if err := iter.Close(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to get followees", err)
+		return nil, err
+	}
+
+	var res []string
+	for start := 0; start < len(followees); start += followerCountChunk {
+		end := min(start+followerCountChunk, len(followees))
+		iter := s.Session.Query(
+			`SELECT user_id, followers FROM follower_counts WHERE user_id IN ?`,
+			followees[start:end],
+		).Iter()
+
+		var count int64
+		for iter.Scan(&id, &count) {
+			if count >= s.CelebrityThreshold {
+				res = append(res, id)
+			}
+		}
+		if err := iter.Close(); err != nil {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to get followee follower counts", err)
+			return nil, err
+		}
+	}
+	s.followees.Set(userID, res, time.Now().Add(celebrityFolloweesTTL))
+	return res, nil
+}
+
+// readFeedSlice reads up to limit posts of one partition of the feed table or
+// posts_by_author that follow pos, skipping posts by the given authors.
+func (s *Store) readFeedSlice(table, keyColumn, key string, limit int, pos *feedPosition, skipAuthors map[string]bool) (feedSlice, error) {
+	stmt := fmt.Sprintf(`SELECT post_id, author_id, body, created_at FROM %s WHERE %s = ?`, table, keyColumn)
+	values := []interface{}{key}
+	if pos != nil {
+		stmt += ` AND (created_at, post_id) < (?, ?)`
+		values = append(values, pos.Created, pos.PostID)
+	}
+	stmt += ` LIMIT ?`
+	values = append(values, limit)
+
+	iter := s.Session.Query(stmt, values...).Iter()
+
+	var res feedSlice
+	var pid, aid, body string
+	var created time.Time
+	rows := 0
+
+	for iter.Scan(&pid, &aid, &body, &created) {
+		rows++
+		res.last = &feedPosition{Created: created, PostID: pid}
+		if skipAuthors[aid] {
+			continue
+		}
+		res.posts = append(res.posts, models.Post{
+			ID:       pid,
+			AuthorID: aid,
+			Body:     body,
+			Created:  created,
+		})
+	}
+
+	if err := iter.Close(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to read posts", err, "table", table)
+		return feedSlice{}, err
+	}
+	if rows < limit {
+		res.last = nil
+	}
+	return res, nil
+}
+
+// mergeFeed interleaves feed sources newest first and returns one page plus
+// the position to continue from (nil once every source is exhausted).
+// Posts older than the last row read from a source that may hold more rows
+// are held back for the next page, since that source could still have posts
+// that sort before them.
+func mergeFeed(limit int, slices []feedSlice) ([]models.Post, *feedPosition) {
+	var horizon *feedPosition
+	for _, sl := range slices {
+		if sl.last != nil && (horizon == nil || sl.last.newer(*horizon)) {
+			horizon = sl.last
+		}
+	}
+
+	var merged []models.Post
+	for _, sl := range slices {
+		for _, p := range sl.posts {
+			if horizon == nil || !horizon.newer(positionOf(p)) {
+				merged = append(merged, p)
+			}
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return positionOf(merged[i]).newer(positionOf(merged[j]))
+	})
+
+	if len(merged) > limit {
+		merged = merged[:limit]
+		next := positionOf(merged[limit-1])
+		return merged, &next
+	}
+	return merged, horizon
+}
+
+func positionOf(p models.Post) feedPosition {
+	return feedPosition{Created: p.Created, PostID: p.ID}
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+)
+
+var mergeBase = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// post returns a post created sec seconds after mergeBase.
+func post(id string, sec int) models.Post {
+	return models.Post{ID: id, Created: mergeBase.Add(time.Duration(sec) * time.Second)}
+}
+
+func ids(posts []models.Post) []string {
+	res := make([]string, len(posts))
+	for i, p := range posts {
+		res[i] = p.ID
+	}
+	return res
+}
+
+func TestMergeFeed_InterleavesNewestFirst(t *testing.T) {
+	feed := feedSlice{posts: []models.Post{post("f3", 30), post("f1", 10)}}
+	celeb := feedSlice{posts: []models.Post{post("c4", 40), post("c2", 20)}}
+
+	got, next := mergeFeed(10, []feedSlice{feed, celeb})
+	if want := []string{"c4", "f3", "c2", "f1"}; !slices.Equal(ids(got), want) {
+		t.Errorf("merged = %v, want %v", ids(got), want)
+	}
+	if next != nil {
+		t.Errorf("next = %+v, want nil once all sources are exhausted", next)
+	}
+}
+
+func TestMergeFeed_TruncatesToLimit(t *testing.T) {
+	feed := feedSlice{posts: []models.Post{post("f3", 30), post("f1", 10)}}
+	celeb := feedSlice{posts: []models.Post{post("c2", 20)}}
+
+	got, next := mergeFeed(2, []feedSlice{feed, celeb})
+	if want := []string{"f3", "c2"}; !slices.Equal(ids(got), want) {
+		t.Errorf("merged = %v, want %v", ids(got), want)
+	}
+	if next == nil || *next != positionOf(post("c2", 20)) {
+		t.Errorf("next = %+v, want the position of c2", next)
+	}
+}
+
+// posts older than the last row of a source with more rows are held back,
+// as that source may still have posts sorting before them
+func TestMergeFeed_HoldsBackPostsPastHorizon(t *testing.T) {
+	feed := feedSlice{
+		posts: []models.Post{post("f5", 50), post("f4", 40)},
+		last:  &feedPosition{Created: mergeBase.Add(40 * time.Second), PostID: "f4"},
+	}
+	celeb := feedSlice{posts: []models.Post{post("c6", 60), post("c1", 10)}}
+
+	got, next := mergeFeed(10, []feedSlice{feed, celeb})
+	if want := []string{"c6", "f5", "f4"}; !slices.Equal(ids(got), want) {
+		t.Errorf("merged = %v, want %v", ids(got), want)
+	}
+	if next == nil || *next != *feed.last {
+		t.Errorf("next = %+v, want the horizon %+v", next, feed.last)
+	}
+}
+
+// posts created in the same millisecond are ordered by post_id descending,
+// like the clustering order of feed_by_user_v2 and posts_by_author
+func TestMergeFeed_TiesOrderedByPostIDDescending(t *testing.T) {
+	feed := feedSlice{posts: []models.Post{post("b", 10), post("a", 10)}}
+	celeb := feedSlice{posts: []models.Post{post("c", 10)}}
+
+	got, _ := mergeFeed(10, []feedSlice{feed, celeb})
+	if want := []string{"c", "b", "a"}; !slices.Equal(ids(got), want) {
+		t.Errorf("merged = %v, want %v", ids(got), want)
+	}
+}
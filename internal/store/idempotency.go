@@ -0,0 +1,81 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+)
+
+// --- Idempotency keys ---
+
+// ErrIdempotencyKeyLost is returned when completing or releasing a key that
+// is no longer held for the request, e.g. because its reservation expired.
+var ErrIdempotencyKeyLost = errors.New("idempotency key no longer held")
+
+// ReserveIdempotencyKey claims an Idempotency-Key of a user for a new request.
+// The reservation expires after lockTTL unless it is completed first, so a
+// request that never finishes does not block the key for long. If the key
+// was already claimed, it returns false together with the stored response
+// (StatusCode 0 if the first request has not finished yet).
+func (s *Store) ReserveIdempotencyKey(userID, key, requestHash string, lockTTL time.Duration) (models.IdempotentResponse, bool, error) {
+	existing := make(map[string]interface{})
+	applied, err := s.Session.Query(`
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, status_code)
+		VALUES (?, ?, ?, 0) IF NOT EXISTS USING TTL ?`,
+		userID, key, requestHash, ttlSeconds(lockTTL),
+	).MapScanCAS(existing)
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to reserve idempotency key", err)
+		return models.IdempotentResponse{}, false, err
+	}
+	if applied {
+		return models.IdempotentResponse{}, true, nil
+	}
+
+	resp := models.IdempotentResponse{}
+	resp.RequestHash, _ = existing["request_hash"].(string)
+	resp.StatusCode, _ = existing["status_code"].(int)
+	resp.ContentType, _ = existing["content_type"].(string)
+	resp.Body, _ = existing["body"].([]byte)
+	return resp, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request holding the key
+// and keeps it for ttl. Like the reservation it is a lightweight transaction,
+// so it only applies while the key is still held for the same request.
+func (s *Store) CompleteIdempotencyKey(userID, key string, resp models.IdempotentResponse, ttl time.Duration) error {
+	applied, err := s.Session.Query(`
+		UPDATE idempotency_keys USING TTL ?
+		SET status_code = ?, content_type = ?, body = ?, request_hash = ?
+		WHERE user_id = ? AND idempotency_key = ?
+		IF request_hash = ?`,
+		ttlSeconds(ttl), resp.StatusCode, resp.ContentType, resp.Body, resp.RequestHash,
+		userID, key, resp.RequestHash,
+	).MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to store idempotent response", err)
+		return err
+	}
+	if !applied {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed, so it can be
+// retried. Only the reservation of the same request is removed.
+func (s *Store) ReleaseIdempotencyKey(userID, key, requestHash string) error {
+	applied, err := s.Session.Query(
+		`DELETE FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ? IF request_hash = ?`,
+		userID, key, requestHash,
+	).MapScanCAS(make(map[string]interface{}))
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to release idempotency key", err)
+		return err
+	}
+	if !applied {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
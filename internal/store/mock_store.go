@@ -1,66 +1,233 @@
 package store
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 
 	"example.com/cassandrafeed/internal/models"
+	"github.com/gocql/gocql"
+	"github.com/google/uuid"
 )
 
-var mockUserCounter int
-
 // MockStore simulates Cassandra operations for testing.
 type MockStore struct {
+	mu         sync.Mutex
 	Users      map[string]string
+	Passwords  map[string]string // user ID -> password hash
 	Followers  map[string][]string
 	Feed       map[string][]models.Post
 	Posts      map[string]models.Post
+	Outbox     []models.OutboxEvent
+	Cursors    map[int]time.Time // outbox shard -> delivered until
+	Processed  map[string]bool
+	Idempotent map[string]models.IdempotentResponse
+	Refresh    map[string]models.Session // refresh token hash -> session
+	Sessions   map[models.Session]string // session -> hash of its current refresh token
+	Revoked    map[string]bool
 	ShouldFail bool // flag to simulate failures
+
+	// CelebrityThreshold mirrors Store.CelebrityThreshold (0 disables)
+	CelebrityThreshold int
 }
 
 // NewMock initializes a new mock store
 func NewMock() *MockStore {
 	return &MockStore{
-		Users:     make(map[string]string),
-		Followers: make(map[string][]string),
-		Feed:      make(map[string][]models.Post),
-		Posts:     make(map[string]models.Post),
+		Users:      make(map[string]string),
+		Passwords:  make(map[string]string),
+		Followers:  make(map[string][]string),
+		Feed:       make(map[string][]models.Post),
+		Posts:      make(map[string]models.Post),
+		Cursors:    make(map[int]time.Time),
+		Processed:  make(map[string]bool),
+		Idempotent: make(map[string]models.IdempotentResponse),
+		Refresh:    make(map[string]models.Session),
+		Sessions:   make(map[models.Session]string),
+		Revoked:    make(map[string]bool),
 	}
 }
 
 func (m *MockStore) Close() {}
 
-// CreateUser simulates creating a new user
-func (m *MockStore) CreateUser(username string) (string, error) {
+// Ping always succeeds, the mock has no connection to lose
+func (m *MockStore) Ping(ctx context.Context) error { return nil }
+
+// CreateUser simulates registering a new user
+func (m *MockStore) CreateUser(username, passwordHash string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return "", errors.New("mock: create user failed")
 	}
-	mockUserCounter++
-	id := fmt.Sprintf("user_%d", mockUserCounter)
+	for _, u := range m.Users {
+		if u == username {
+			return "", ErrUsernameTaken
+		}
+	}
+	id := uuid.NewString()
 	m.Users[id] = username
+	m.Passwords[id] = passwordHash
 	return id, nil
 }
 
 // CreateFollow simulates creating a follow relationship
 func (m *MockStore) CreateFollow(followerID, followeeID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return errors.New("mock: follow failed")
 	}
 	// Key is followeeID so that GetFollowers(followeeID) returns the followerID
+	for _, id := range m.Followers[followeeID] {
+		if id == followerID {
+			return ErrAlreadyFollowing
+		}
+	}
 	m.Followers[followeeID] = append(m.Followers[followeeID], followerID)
 	return nil
 }
 
+// DeleteFollowWithOutbox simulates the atomic unfollow + outbox event write
+func (m *MockStore) DeleteFollowWithOutbox(followerID, followeeID string, event models.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: unfollow with outbox failed")
+	}
+	m.deleteFollow(followerID, followeeID)
+	m.Outbox = append(m.Outbox, prepareOutboxEvent(event, followerID))
+	return nil
+}
+
+func (m *MockStore) deleteFollow(followerID, followeeID string) {
+	followers := m.Followers[followeeID]
+	for i, id := range followers {
+		if id == followerID {
+			m.Followers[followeeID] = append(followers[:i:i], followers[i+1:]...)
+			return
+		}
+	}
+}
+
 // GetFollowers returns all followers of a given user
 func (m *MockStore) GetFollowers(userID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return nil, errors.New("mock: get followers failed")
 	}
 	return m.Followers[userID], nil
 }
 
+// GetFollowersPage pages through the sorted followers of a user
+func (m *MockStore) GetFollowersPage(userID string, limit int, cursor string) ([]string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return nil, "", errors.New("mock: get followers page failed")
+	}
+	if err := checkUUID(userID); err != nil {
+		return nil, "", err
+	}
+	ids := append([]string(nil), m.Followers[userID]...)
+	sort.Strings(ids)
+	return pageByOffset(ids, limit, cursor)
+}
+
+// GetFollowingPage pages through the sorted users a user follows
+func (m *MockStore) GetFollowingPage(userID string, limit int, cursor string) ([]string, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return nil, "", errors.New("mock: get following page failed")
+	}
+	if err := checkUUID(userID); err != nil {
+		return nil, "", err
+	}
+	ids := m.following(userID)
+	sort.Strings(ids)
+	return pageByOffset(ids, limit, cursor)
+}
+
+// GetFollowCounts counts the followers and followees of a user
+func (m *MockStore) GetFollowCounts(userID string) (models.FollowCounts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return models.FollowCounts{}, errors.New("mock: get follow counts failed")
+	}
+	if err := checkUUID(userID); err != nil {
+		return models.FollowCounts{}, err
+	}
+	return models.FollowCounts{
+		Followers: int64(len(m.Followers[userID])),
+		Following: int64(len(m.following(userID))),
+	}, nil
+}
+
+func (m *MockStore) following(userID string) []string {
+	var res []string
+	for followeeID, followers := range m.Followers {
+		for _, id := range followers {
+			if id == userID {
+				res = append(res, followeeID)
+			}
+		}
+	}
+	return res
+}
+
+// IsCelebrity reports whether the author has reached CelebrityThreshold followers
+func (m *MockStore) IsCelebrity(authorID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return false, errors.New("mock: is celebrity failed")
+	}
+	return m.isCelebrity(authorID), nil
+}
+
+func (m *MockStore) isCelebrity(authorID string) bool {
+	return m.CelebrityThreshold > 0 && len(m.Followers[authorID]) >= m.CelebrityThreshold
+}
+
+// feedFor returns the user's materialized feed, merged newest first with the
+// posts of followed celebrities when there are any
+func (m *MockStore) feedFor(userID string) []models.Post {
+	celebrities := make(map[string]bool)
+	for _, followeeID := range m.following(userID) {
+		if m.isCelebrity(followeeID) {
+			celebrities[followeeID] = true
+		}
+	}
+	if len(celebrities) == 0 {
+		return m.Feed[userID]
+	}
+
+	var posts []models.Post
+	for _, p := range m.Feed[userID] {
+		if !celebrities[p.AuthorID] {
+			posts = append(posts, p)
+		}
+	}
+	for _, p := range m.Posts {
+		if celebrities[p.AuthorID] {
+			posts = append(posts, p)
+		}
+	}
+	sort.SliceStable(posts, func(i, j int) bool { return posts[i].Created.After(posts[j].Created) })
+	return posts
+}
+
 // AddPost simulates adding a post
 func (m *MockStore) AddPost(post models.Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return errors.New("mock: add post failed")
 	}
@@ -68,29 +235,172 @@ func (m *MockStore) AddPost(post models.Post) error {
 	return nil
 }
 
-// AddToFeed simulates adding a post to a user's feed
+// GetPost returns a stored post or gocql.ErrNotFound
+func (m *MockStore) GetPost(postID string) (models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return models.Post{}, errors.New("mock: get post failed")
+	}
+	if err := checkUUID(postID); err != nil {
+		return models.Post{}, err
+	}
+	post, ok := m.Posts[postID]
+	if !ok {
+		return models.Post{}, gocql.ErrNotFound
+	}
+	return post, nil
+}
+
+// AddToFeed simulates adding a post to a user's feed.
+// Like a Cassandra insert, writing the same post again overwrites it.
 func (m *MockStore) AddToFeed(userID string, post models.Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return errors.New("mock: add to feed failed")
 	}
+	for i, p := range m.Feed[userID] {
+		if p.ID == post.ID {
+			m.Feed[userID][i] = post
+			return nil
+		}
+	}
 	m.Feed[userID] = append(m.Feed[userID], post)
 	return nil
 }
 
+// AddToFeeds simulates adding a post to several feeds
+func (m *MockStore) AddToFeeds(userIDs []string, post models.Post) error {
+	for _, uid := range userIDs {
+		if err := m.AddToFeed(uid, post); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateInFeeds rewrites a post in the feeds that already hold it
+func (m *MockStore) UpdateInFeeds(userIDs []string, post models.Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: update in feeds failed")
+	}
+	for _, uid := range userIDs {
+		for i, p := range m.Feed[uid] {
+			if p.ID == post.ID {
+				m.Feed[uid][i] = post
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveFromFeed drops a single post from a user's feed
+func (m *MockStore) RemoveFromFeed(userID string, post models.Post) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: remove from feed failed")
+	}
+	var kept []models.Post
+	for _, p := range m.Feed[userID] {
+		if p.ID != post.ID {
+			kept = append(kept, p)
+		}
+	}
+	m.Feed[userID] = kept
+	return nil
+}
+
+// RemoveAuthorFromFeed drops all posts of an author from a user's feed
+func (m *MockStore) RemoveAuthorFromFeed(userID, authorID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: remove author from feed failed")
+	}
+	var kept []models.Post
+	for _, p := range m.Feed[userID] {
+		if p.AuthorID != authorID {
+			kept = append(kept, p)
+		}
+	}
+	m.Feed[userID] = kept
+	return nil
+}
+
 // GetFeed retrieves a user's feed with an optional limit
 func (m *MockStore) GetFeed(userID string, limit int) ([]models.Post, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if m.ShouldFail {
 		return nil, errors.New("mock: get feed failed")
 	}
-	posts := m.Feed[userID]
+	posts := m.feedFor(userID)
 	if len(posts) > limit {
 		return posts[:limit], nil
 	}
 	return posts, nil
 }
 
+// GetFeedPage pages through a user's feed using the slice offset as cursor
+func (m *MockStore) GetFeedPage(userID string, limit int, cursor string) ([]models.Post, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return nil, "", errors.New("mock: get feed page failed")
+	}
+	return pageByOffset(m.feedFor(userID), limit, cursor)
+}
+
+// GetAuthorPostsPage pages through an author's posts, newest first
+func (m *MockStore) GetAuthorPostsPage(authorID string, limit int, cursor string) ([]models.Post, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return nil, "", errors.New("mock: get author posts page failed")
+	}
+	if err := checkUUID(authorID); err != nil {
+		return nil, "", err
+	}
+	var posts []models.Post
+	for _, p := range m.Posts {
+		if p.AuthorID == authorID {
+			posts = append(posts, p)
+		}
+	}
+	sort.Slice(posts, func(i, j int) bool { return positionOf(posts[i]).newer(positionOf(posts[j])) })
+	return pageByOffset(posts, limit, cursor)
+}
+
+// pageByOffset pages through items using the slice offset as cursor
+func pageByOffset[T any](items []T, limit int, cursor string) ([]T, string, error) {
+	offset := 0
+	if cursor != "" {
+		state, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		if offset, err = strconv.Atoi(string(state)); err != nil || offset < 0 {
+			return nil, "", ErrInvalidCursor
+		}
+	}
+	if offset >= len(items) {
+		return nil, "", nil
+	}
+	end := offset + limit
+	if end >= len(items) {
+		return items[offset:], "", nil
+	}
+	return items[offset:end], encodeCursor([]byte(strconv.Itoa(end))), nil
+}
+
 // GetUserIDByUsername returns the user ID for a given username
 func (m *MockStore) GetUserIDByUsername(username string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	for id, u := range m.Users {
 		if u == username {
 			return id, nil
@@ -99,13 +409,297 @@ func (m *MockStore) GetUserIDByUsername(username string) (string, error) {
 	return "", nil
 }
 
+// GetCredentials returns the stored credentials or gocql.ErrNotFound
+func (m *MockStore) GetCredentials(username string) (models.Credentials, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return models.Credentials{}, errors.New("mock: get credentials failed")
+	}
+	for id, u := range m.Users {
+		if u == username {
+			return models.Credentials{UserID: id, PasswordHash: m.Passwords[id]}, nil
+		}
+	}
+	return models.Credentials{}, gocql.ErrNotFound
+}
+
+// GetUser returns a stored user or gocql.ErrNotFound
+func (m *MockStore) GetUser(userID string) (models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return models.User{}, errors.New("mock: get user failed")
+	}
+	if err := checkUUID(userID); err != nil {
+		return models.User{}, err
+	}
+	username, ok := m.Users[userID]
+	if !ok {
+		return models.User{}, gocql.ErrNotFound
+	}
+	return models.User{ID: userID, Username: username}, nil
+}
+
+// AddPostWithOutbox simulates the atomic post + outbox event write
+func (m *MockStore) AddPostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: add post with outbox failed")
+	}
+	m.Posts[post.ID] = post
+	m.Outbox = append(m.Outbox, prepareOutboxEvent(event, post.ID))
+	return nil
+}
+
+// UpdatePostWithOutbox simulates the atomic post update + outbox event write
+func (m *MockStore) UpdatePostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: update post with outbox failed")
+	}
+	if _, ok := m.Posts[post.ID]; !ok {
+		return gocql.ErrNotFound
+	}
+	m.Posts[post.ID] = post
+	m.Outbox = append(m.Outbox, prepareOutboxEvent(event, post.ID))
+	return nil
+}
+
+// DeletePostWithOutbox simulates the atomic post deletion + outbox event write
+func (m *MockStore) DeletePostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: delete post with outbox failed")
+	}
+	delete(m.Posts, post.ID)
+	m.Outbox = append(m.Outbox, prepareOutboxEvent(event, post.ID))
+	return nil
+}
+
+// GetPendingOutbox returns pending events of a shard created at or after
+// since in insertion order
+func (m *MockStore) GetPendingOutbox(shard int, since time.Time, limit int) ([]models.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return nil, errors.New("mock: get pending outbox failed")
+	}
+	var res []models.OutboxEvent
+	for _, e := range m.Outbox {
+		if e.Shard == shard && !e.Created.Before(since) && len(res) < limit {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// GetOutboxCursor returns the recorded cursor of a shard
+func (m *MockStore) GetOutboxCursor(shard int) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return time.Time{}, errors.New("mock: get outbox cursor failed")
+	}
+	return m.Cursors[shard], nil
+}
+
+// SaveOutboxCursor records the cursor of a shard
+func (m *MockStore) SaveOutboxCursor(shard int, deliveredUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: save outbox cursor failed")
+	}
+	m.Cursors[shard] = deliveredUntil
+	return nil
+}
+
+// MarkOutboxDelivered removes a delivered event from the mock outbox
+func (m *MockStore) MarkOutboxDelivered(event models.OutboxEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: mark outbox delivered failed")
+	}
+	for i, e := range m.Outbox {
+		if e.ID == event.ID {
+			m.Outbox = append(m.Outbox[:i], m.Outbox[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// IsEventProcessed reports whether the event was marked processed
+func (m *MockStore) IsEventProcessed(eventID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return false, errors.New("mock: is event processed failed")
+	}
+	return m.Processed[eventID], nil
+}
+
+// MarkEventProcessed records a processed event; the TTL is ignored
+func (m *MockStore) MarkEventProcessed(eventID string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: mark event processed failed")
+	}
+	m.Processed[eventID] = true
+	return nil
+}
+
+// ReserveIdempotencyKey claims a key or returns the response stored for it; the TTL is ignored
+func (m *MockStore) ReserveIdempotencyKey(userID, key, requestHash string, lockTTL time.Duration) (models.IdempotentResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return models.IdempotentResponse{}, false, errors.New("mock: reserve idempotency key failed")
+	}
+	if resp, ok := m.Idempotent[userID+"/"+key]; ok {
+		return resp, false, nil
+	}
+	m.Idempotent[userID+"/"+key] = models.IdempotentResponse{RequestHash: requestHash}
+	return models.IdempotentResponse{}, true, nil
+}
+
+// CompleteIdempotencyKey stores the response for a key
+func (m *MockStore) CompleteIdempotencyKey(userID, key string, resp models.IdempotentResponse, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: complete idempotency key failed")
+	}
+	if held, ok := m.Idempotent[userID+"/"+key]; !ok || held.RequestHash != resp.RequestHash {
+		return ErrIdempotencyKeyLost
+	}
+	m.Idempotent[userID+"/"+key] = resp
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key held for the request
+func (m *MockStore) ReleaseIdempotencyKey(userID, key, requestHash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: release idempotency key failed")
+	}
+	if held, ok := m.Idempotent[userID+"/"+key]; !ok || held.RequestHash != requestHash {
+		return ErrIdempotencyKeyLost
+	}
+	delete(m.Idempotent, userID+"/"+key)
+	return nil
+}
+
 // ---------------------------------------------
+// CreateSession stores a session and its refresh token hash; the TTL is ignored
+func (m *MockStore) CreateSession(sess models.Session, tokenHash string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: create session failed")
+	}
+	m.Refresh[tokenHash] = sess
+	m.Sessions[sess] = tokenHash
+	return nil
+}
+
+// RotateRefreshToken swaps the current refresh token hash of a session
+func (m *MockStore) RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (models.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return models.Session{}, errors.New("mock: rotate refresh token failed")
+	}
+	sess, ok := m.Refresh[oldHash]
+	if !ok {
+		return models.Session{}, gocql.ErrNotFound
+	}
+	current, ok := m.Sessions[sess]
+	if !ok {
+		return models.Session{}, gocql.ErrNotFound
+	}
+	if current != oldHash {
+		return sess, ErrRefreshTokenReused
+	}
+	m.Refresh[newHash] = sess
+	m.Sessions[sess] = newHash
+	return sess, nil
+}
+
+// RevokeSession removes a session
+func (m *MockStore) RevokeSession(userID, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: revoke session failed")
+	}
+	delete(m.Sessions, models.Session{ID: sessionID, UserID: userID})
+	return nil
+}
+
+// RevokeAllSessions removes every session of a user and returns their IDs
+func (m *MockStore) RevokeAllSessions(userID string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return nil, errors.New("mock: revoke sessions failed")
+	}
+	var ids []string
+	for sess := range m.Sessions {
+		if sess.UserID == userID {
+			ids = append(ids, sess.ID)
+			delete(m.Sessions, sess)
+		}
+	}
+	return ids, nil
+}
+
+// RevokeToken records a revoked access token ID; the TTL is ignored
+func (m *MockStore) RevokeToken(tokenID string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return errors.New("mock: revoke token failed")
+	}
+	m.Revoked[tokenID] = true
+	return nil
+}
+
+// IsTokenRevoked reports whether the access token ID was revoked
+func (m *MockStore) IsTokenRevoked(tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ShouldFail {
+		return false, errors.New("mock: is token revoked failed")
+	}
+	return m.Revoked[tokenID], nil
+}
+
+// checkUUID fails like Cassandra does for an ID that is not a UUID
+func checkUUID(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("mock: can not marshal %q into uuid", id)
+	}
+	return nil
+}
+
 // MockStoreFail always returns errors for negative tests
 type MockStoreFail struct{}
 
 func (m *MockStoreFail) Close() {}
 
-func (m *MockStoreFail) CreateUser(username string) (string, error) {
+func (m *MockStoreFail) Ping(ctx context.Context) error {
+	return errors.New("mock store ping failed")
+}
+
+func (m *MockStoreFail) CreateUser(username, passwordHash string) (string, error) {
 	return "", errors.New("mock store create user failed")
 }
 
@@ -113,22 +707,150 @@ func (m *MockStoreFail) CreateFollow(followerID, followeeID string) error {
 	return errors.New("mock store create follow failed")
 }
 
+func (m *MockStoreFail) DeleteFollowWithOutbox(followerID, followeeID string, event models.OutboxEvent) error {
+	return errors.New("mock store delete follow with outbox failed")
+}
+
 func (m *MockStoreFail) GetUserIDByUsername(username string) (string, error) {
 	return "", errors.New("mock store get user by username failed")
 }
 
+func (m *MockStoreFail) GetCredentials(username string) (models.Credentials, error) {
+	return models.Credentials{}, errors.New("mock store get credentials failed")
+}
+
+func (m *MockStoreFail) GetUser(userID string) (models.User, error) {
+	return models.User{}, errors.New("mock store get user failed")
+}
+
 func (m *MockStoreFail) GetFollowers(userID string) ([]string, error) {
 	return nil, errors.New("mock store get followers failed")
 }
 
+func (m *MockStoreFail) GetFollowersPage(userID string, limit int, cursor string) ([]string, string, error) {
+	return nil, "", errors.New("mock store get followers page failed")
+}
+
+func (m *MockStoreFail) GetFollowingPage(userID string, limit int, cursor string) ([]string, string, error) {
+	return nil, "", errors.New("mock store get following page failed")
+}
+
+func (m *MockStoreFail) GetFollowCounts(userID string) (models.FollowCounts, error) {
+	return models.FollowCounts{}, errors.New("mock store get follow counts failed")
+}
+
+func (m *MockStoreFail) IsCelebrity(authorID string) (bool, error) {
+	return false, errors.New("mock store is celebrity failed")
+}
+
 func (m *MockStoreFail) AddPost(post models.Post) error {
 	return errors.New("mock store add post failed")
 }
 
+func (m *MockStoreFail) GetPost(postID string) (models.Post, error) {
+	return models.Post{}, errors.New("mock store get post failed")
+}
+
+func (m *MockStoreFail) GetAuthorPostsPage(authorID string, limit int, cursor string) ([]models.Post, string, error) {
+	return nil, "", errors.New("mock store get author posts page failed")
+}
+
+func (m *MockStoreFail) RemoveFromFeed(userID string, post models.Post) error {
+	return errors.New("mock store remove from feed failed")
+}
+
 func (m *MockStoreFail) AddToFeed(userID string, post models.Post) error {
 	return errors.New("mock store add to feed failed")
 }
 
+func (m *MockStoreFail) AddToFeeds(userIDs []string, post models.Post) error {
+	return errors.New("mock store add to feeds failed")
+}
+
+func (m *MockStoreFail) UpdateInFeeds(userIDs []string, post models.Post) error {
+	return errors.New("mock store update in feeds failed")
+}
+
+func (m *MockStoreFail) RemoveAuthorFromFeed(userID, authorID string) error {
+	return errors.New("mock store remove author from feed failed")
+}
+
 func (m *MockStoreFail) GetFeed(userID string, limit int) ([]models.Post, error) {
 	return nil, errors.New("mock store get feed failed")
 }
+
+func (m *MockStoreFail) GetFeedPage(userID string, limit int, cursor string) ([]models.Post, string, error) {
+	return nil, "", errors.New("mock store get feed page failed")
+}
+
+func (m *MockStoreFail) AddPostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	return errors.New("mock store add post with outbox failed")
+}
+
+func (m *MockStoreFail) UpdatePostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	return errors.New("mock store update post with outbox failed")
+}
+
+func (m *MockStoreFail) DeletePostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	return errors.New("mock store delete post with outbox failed")
+}
+
+func (m *MockStoreFail) GetPendingOutbox(shard int, since time.Time, limit int) ([]models.OutboxEvent, error) {
+	return nil, errors.New("mock store get pending outbox failed")
+}
+
+func (m *MockStoreFail) GetOutboxCursor(shard int) (time.Time, error) {
+	return time.Time{}, errors.New("mock store get outbox cursor failed")
+}
+
+func (m *MockStoreFail) SaveOutboxCursor(shard int, deliveredUntil time.Time) error {
+	return errors.New("mock store save outbox cursor failed")
+}
+
+func (m *MockStoreFail) MarkOutboxDelivered(event models.OutboxEvent) error {
+	return errors.New("mock store mark outbox delivered failed")
+}
+
+func (m *MockStoreFail) IsEventProcessed(eventID string) (bool, error) {
+	return false, errors.New("mock store is event processed failed")
+}
+
+func (m *MockStoreFail) MarkEventProcessed(eventID string, ttl time.Duration) error {
+	return errors.New("mock store mark event processed failed")
+}
+
+func (m *MockStoreFail) ReserveIdempotencyKey(userID, key, requestHash string, lockTTL time.Duration) (models.IdempotentResponse, bool, error) {
+	return models.IdempotentResponse{}, false, errors.New("mock store reserve idempotency key failed")
+}
+
+func (m *MockStoreFail) CompleteIdempotencyKey(userID, key string, resp models.IdempotentResponse, ttl time.Duration) error {
+	return errors.New("mock store complete idempotency key failed")
+}
+
+func (m *MockStoreFail) ReleaseIdempotencyKey(userID, key, requestHash string) error {
+	return errors.New("mock store release idempotency key failed")
+}
+
+func (m *MockStoreFail) CreateSession(sess models.Session, tokenHash string, ttl time.Duration) error {
+	return errors.New("mock store create session failed")
+}
+
+func (m *MockStoreFail) RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (models.Session, error) {
+	return models.Session{}, errors.New("mock store rotate refresh token failed")
+}
+
+func (m *MockStoreFail) RevokeSession(userID, sessionID string) error {
+	return errors.New("mock store revoke session failed")
+}
+
+func (m *MockStoreFail) RevokeAllSessions(userID string) ([]string, error) {
+	return nil, errors.New("mock store revoke sessions failed")
+}
+
+func (m *MockStoreFail) RevokeToken(tokenID string, ttl time.Duration) error {
+	return errors.New("mock store revoke token failed")
+}
+
+func (m *MockStoreFail) IsTokenRevoked(tokenID string) (bool, error) {
+	return false, errors.New("mock store is token revoked failed")
+}
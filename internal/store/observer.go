@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"example.com/cassandrafeed/internal/metrics"
+	"example.com/cassandrafeed/internal/tracing"
+	"github.com/gocql/gocql"
+)
+
+// queryObserver reports every executed query and batch to the metrics and,
+// if it ran in the context of a trace, as a span of that trace.
+type queryObserver struct{}
+
+func (queryObserver) ObserveQuery(ctx context.Context, q gocql.ObservedQuery) {
+	op, table := statementLabels(q.Statement)
+	observe(ctx, op, table, q.Start, q.End, q.Err)
+}
+
+func (queryObserver) ObserveBatch(ctx context.Context, b gocql.ObservedBatch) {
+	table := ""
+	if len(b.Statements) > 0 {
+		_, table = statementLabels(b.Statements[0])
+	}
+	observe(ctx, "BATCH", table, b.Start, b.End, b.Err)
+}
+
+func observe(ctx context.Context, op, table string, start, end time.Time, err error) {
+	metrics.ObserveCassandra(op, table, end.Sub(start), err)
+	tracing.RecordCassandra(ctx, op, table, start, end, err)
+}
+
+// statementLabels extracts the operation and table of a CQL statement, e.g.
+// "SELECT", "feed_by_user", keeping the metric label set small.
+func statementLabels(stmt string) (op, table string) {
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return "UNKNOWN", ""
+	}
+	op = strings.ToUpper(fields[0])
+
+	// The table follows FROM, INTO or UPDATE, or TABLE in DDL statements.
+	for i, f := range fields[:len(fields)-1] {
+		switch strings.ToUpper(f) {
+		case "FROM", "INTO", "UPDATE", "TABLE":
+			return op, strings.TrimRight(fields[i+1], "(;")
+		}
+	}
+	return op, ""
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"hash/fnv"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+	"github.com/gocql/gocql"
+)
+
+// OutboxShards is the number of partitions the outbox table is spread over,
+// so that pending events don't all land on a single Cassandra partition.
+const OutboxShards = 16
+
+// outboxShard maps an aggregate ID (e.g. post ID) to its outbox shard.
+func outboxShard(id string) int {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return int(h.Sum32() % OutboxShards)
+}
+
+// --- Outbox operations ---
+
+// AddPostWithOutbox writes the post and its pending event in one logged batch,
+// so either both rows become visible or neither does.
+func (s *Store) AddPostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	event = prepareOutboxEvent(event, post.ID)
+
+	batch := s.Session.NewBatch(gocql.LoggedBatch)
+	addPostInsert(batch, post)
+	addOutboxInsert(batch, event)
+
+	if err := s.Session.ExecuteBatch(batch); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to add post with outbox event", err)
+		return err
+	}
+
+	logg.DebugContext(s.logCtx(), "store", "Post and outbox event stored (post content anonymized)")
+	return nil
+}
+
+// UpdatePostWithOutbox rewrites the post body and queues its update event.
+// The posts row and then the author index are updated conditionally, so an
+// update racing a deletion fails with gocql.ErrNotFound instead of
+// resurrecting rows that hold only a body. Conditional updates cannot share
+// a batch with other partitions, so the event is queued last; if that fails
+// the caller gets the error and can repeat the update.
+func (s *Store) UpdatePostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	for _, q := range []struct {
+		stmt   string
+		values []interface{}
+	}{
+		{`UPDATE posts SET body = ? WHERE post_id = ? IF EXISTS`, []interface{}{post.Body, post.ID}},
+		{`UPDATE posts_by_author SET body = ? WHERE author_id = ? AND created_at = ? AND post_id = ? IF EXISTS`,
+			[]interface{}{post.Body, post.AuthorID, post.Created, post.ID}},
+	} {
+		applied, err := s.Session.Query(q.stmt, q.values...).MapScanCAS(make(map[string]interface{}))
+		if err != nil {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to update post", err)
+			return err
+		}
+		if !applied {
+			return gocql.ErrNotFound
+		}
+	}
+
+	batch := s.Session.NewBatch(gocql.UnloggedBatch)
+	addOutboxInsert(batch, prepareOutboxEvent(event, post.ID))
+	if err := s.Session.ExecuteBatch(batch); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to store post update outbox event", err)
+		return err
+	}
+
+	logg.DebugContext(s.logCtx(), "store", "Post updated and outbox event stored (post content anonymized)")
+	return nil
+}
+
+// DeletePostWithOutbox deletes the post and queues its deletion event atomically.
+func (s *Store) DeletePostWithOutbox(post models.Post, event models.OutboxEvent) error {
+	batch := s.Session.NewBatch(gocql.LoggedBatch)
+	batch.Query(`DELETE FROM posts WHERE post_id = ?`, post.ID)
+	batch.Query(
+		`DELETE FROM posts_by_author WHERE author_id = ? AND created_at = ? AND post_id = ?`,
+		post.AuthorID, post.Created, post.ID,
+	)
+	addOutboxInsert(batch, prepareOutboxEvent(event, post.ID))
+
+	if err := s.Session.ExecuteBatch(batch); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to delete post with outbox event", err)
+		return err
+	}
+
+	logg.DebugContext(s.logCtx(), "store", "Post deleted and outbox event stored (post ID anonymized)")
+	return nil
+}
+
+// GetPendingOutbox returns up to limit undelivered events of a shard created
+// at or after since, oldest first; a zero since reads the whole shard.
+// Starting past the events already delivered skips their tombstones.
+func (s *Store) GetPendingOutbox(shard int, since time.Time, limit int) ([]models.OutboxEvent, error) {
+	stmt := `SELECT event_id, event_key, payload, created_at, trace_context FROM outbox WHERE shard = ?`
+	values := []interface{}{shard}
+	if !since.IsZero() {
+		stmt += ` AND event_id >= minTimeuuid(?)`
+		values = append(values, since)
+	}
+	stmt += ` LIMIT ?`
+	values = append(values, limit)
+
+	iter := s.Session.Query(stmt, values...).Iter()
+
+	var res []models.OutboxEvent
+	var id gocql.UUID
+	var key string
+	var payload []byte
+	var created time.Time
+	var traceContext map[string]string
+
+	for iter.Scan(&id, &key, &payload, &created, &traceContext) {
+		res = append(res, models.OutboxEvent{
+			ID:           id.String(),
+			Shard:        shard,
+			Key:          key,
+			Payload:      payload,
+			Created:      created,
+			TraceContext: traceContext,
+		})
+		payload, traceContext = nil, nil
+	}
+
+	if err := iter.Close(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to read pending outbox events", err)
+		return nil, err
+	}
+	return res, nil
+}
+
+// MarkOutboxDelivered removes an event from the outbox once it has been published.
+func (s *Store) MarkOutboxDelivered(event models.OutboxEvent) error {
+	if err := s.Session.Query(
+		`DELETE FROM outbox WHERE shard = ? AND event_id = ?`,
+		event.Shard, event.ID,
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to mark outbox event delivered", err)
+		return err
+	}
+	return nil
+}
+
+// GetOutboxCursor returns the creation time of the last event delivered from
+// a shard, or the zero time if none was recorded.
+func (s *Store) GetOutboxCursor(shard int) (time.Time, error) {
+	var until time.Time
+	err := s.Session.Query(
+		`SELECT delivered_until FROM outbox_cursors WHERE shard = ?`,
+		shard,
+	).Scan(&until)
+	if err == gocql.ErrNotFound {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to read outbox cursor", err, "shard", shard)
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+// SaveOutboxCursor records the creation time of the last event delivered
+// from a shard.
+func (s *Store) SaveOutboxCursor(shard int, deliveredUntil time.Time) error {
+	if err := s.Session.Query(
+		`UPDATE outbox_cursors SET delivered_until = ? WHERE shard = ?`,
+		deliveredUntil, shard,
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to save outbox cursor", err, "shard", shard)
+		return err
+	}
+	return nil
+}
+
+// addOutboxInsert appends the insert of a prepared outbox event to a batch.
+func addOutboxInsert(batch *gocql.Batch, event models.OutboxEvent) {
+	batch.Query(`
+		INSERT INTO outbox (shard, event_id, event_key, payload, created_at, trace_context)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		event.Shard, event.ID, event.Key, event.Payload, event.Created, event.TraceContext,
+	)
+}
+
+// prepareOutboxEvent fills the ID, shard and timestamp of a new outbox event.
+func prepareOutboxEvent(event models.OutboxEvent, aggregateID string) models.OutboxEvent {
+	if event.ID == "" {
+		event.ID = gocql.TimeUUID().String()
+	}
+	if event.Created.IsZero() {
+		event.Created = time.Now()
+	}
+	event.Shard = outboxShard(aggregateID)
+	return event
+}
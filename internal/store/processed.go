@@ -0,0 +1,39 @@
+package store
+
+import (
+	"time"
+
+	"github.com/gocql/gocql"
+)
+
+// --- Processed events ledger ---
+
+// IsEventProcessed reports whether the worker already finished the event.
+func (s *Store) IsEventProcessed(eventID string) (bool, error) {
+	var processed time.Time
+	err := s.Session.Query(
+		`SELECT processed_at FROM processed_events WHERE event_id = ?`,
+		eventID,
+	).Scan(&processed)
+	if err != nil {
+		if err == gocql.ErrNotFound {
+			return false, nil
+		}
+		logg.ErrorContext(s.logCtx(), "store", "Failed to look up processed event", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// MarkEventProcessed records a finished event. The entry expires after ttl,
+// by which time Kafka can no longer redeliver the event.
+func (s *Store) MarkEventProcessed(eventID string, ttl time.Duration) error {
+	if err := s.Session.Query(
+		`INSERT INTO processed_events (event_id, processed_at) VALUES (?, ?) USING TTL ?`,
+		eventID, time.Now(), ttlSeconds(ttl),
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to mark event processed", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"example.com/cassandrafeed/internal/models"
+	"github.com/gocql/gocql"
+)
+
+// --- Sessions ---
+
+// ErrRefreshTokenReused is returned by RotateRefreshToken for a refresh token
+// that was already exchanged. Someone else may hold a copy of it, so the
+// session it belongs to should be revoked.
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+// CreateSession starts a session whose first refresh token has tokenHash.
+// The session ends after ttl unless its refresh token is rotated.
+func (s *Store) CreateSession(sess models.Session, tokenHash string, ttl time.Duration) error {
+	now := time.Now()
+	batch := s.Session.NewBatch(gocql.LoggedBatch)
+	batch.Query(
+		`INSERT INTO refresh_tokens (token_hash, user_id, session_id, created_at) VALUES (?, ?, ?, ?) USING TTL ?`,
+		tokenHash, sess.UserID, sess.ID, now, ttlSeconds(ttl),
+	)
+	batch.Query(
+		`INSERT INTO sessions_by_user (user_id, session_id, token_hash, created_at) VALUES (?, ?, ?, ?) USING TTL ?`,
+		sess.UserID, sess.ID, tokenHash, now, ttlSeconds(ttl),
+	)
+	if err := s.Session.ExecuteBatch(batch); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to create session", err)
+		return err
+	}
+	return nil
+}
+
+// RotateRefreshToken exchanges the refresh token with oldHash for the one
+// with newHash and returns its session, which is extended by ttl. It returns
+// gocql.ErrNotFound if the token is unknown or expired or its session was
+// revoked, and ErrRefreshTokenReused together with the session if the token
+// was already exchanged.
+func (s *Store) RotateRefreshToken(oldHash, newHash string, ttl time.Duration) (models.Session, error) {
+	var sess models.Session
+	err := s.Session.Query(
+		`SELECT session_id, user_id FROM refresh_tokens WHERE token_hash = ?`,
+		oldHash,
+	).Scan(&sess.ID, &sess.UserID)
+	if err != nil {
+		if err != gocql.ErrNotFound {
+			logg.ErrorContext(s.logCtx(), "store", "Failed to get refresh token", err)
+		}
+		return models.Session{}, err
+	}
+	if sess.ID == "" {
+		// Issued before sessions existed
+		return models.Session{}, gocql.ErrNotFound
+	}
+
+	// Store the new token first: if the swap below fails, it is never handed
+	// out and simply expires.
+	if err := s.Session.Query(
+		`INSERT INTO refresh_tokens (token_hash, user_id, session_id, created_at) VALUES (?, ?, ?, ?) USING TTL ?`,
+		newHash, sess.UserID, sess.ID, time.Now(), ttlSeconds(ttl),
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to save refresh token", err)
+		return models.Session{}, err
+	}
+
+	// The conditional update lets only one of two concurrent refreshes win,
+	// and tells a revoked session from a reused token.
+	current := make(map[string]interface{})
+	applied, err := s.Session.Query(`
+		UPDATE sessions_by_user USING TTL ? SET token_hash = ?
+		WHERE user_id = ? AND session_id = ?
+		IF token_hash = ?`,
+		ttlSeconds(ttl), newHash, sess.UserID, sess.ID, oldHash,
+	).MapScanCAS(current)
+	if err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to rotate refresh token", err)
+		return models.Session{}, err
+	}
+	if !applied {
+		if hash, _ := current["token_hash"].(string); hash == "" {
+			return models.Session{}, gocql.ErrNotFound
+		}
+		return sess, ErrRefreshTokenReused
+	}
+	return sess, nil
+}
+
+// RevokeSession ends a session of userID; its refresh token stops working.
+func (s *Store) RevokeSession(userID, sessionID string) error {
+	if err := s.Session.Query(
+		`DELETE FROM sessions_by_user WHERE user_id = ? AND session_id = ?`,
+		userID, sessionID,
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to revoke session", err)
+		return err
+	}
+	return nil
+}
+
+// RevokeAllSessions ends every session of userID and returns their IDs.
+func (s *Store) RevokeAllSessions(userID string) ([]string, error) {
+	iter := s.Session.Query(`SELECT session_id FROM sessions_by_user WHERE user_id = ?`, userID).Iter()
+	var id string
+	var ids []string
+	for iter.Scan(&id) {
+		ids = append(ids, id)
+	}
+	if err := iter.Close(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to list sessions", err)
+		return nil, err
+	}
+
+	if err := s.Session.Query(`DELETE FROM sessions_by_user WHERE user_id = ?`, userID).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to revoke sessions", err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// RevokeToken adds an access token ID or session ID to the revocation list.
+// The entry expires after ttl, by which time the tokens have expired.
+func (s *Store) RevokeToken(tokenID string, ttl time.Duration) error {
+	if err := s.Session.Query(
+		`INSERT INTO revoked_tokens (token_id, revoked_at) VALUES (?, ?) USING TTL ?`,
+		tokenID, time.Now(), ttlSeconds(ttl),
+	).Exec(); err != nil {
+		logg.ErrorContext(s.logCtx(), "store", "Failed to revoke token", err)
+		return err
+	}
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token ID or session ID is on the
+// revocation list.
+func (s *Store) IsTokenRevoked(tokenID string) (bool, error) {
+	var revoked time.Time
+	err := s.Session.Query(
+		`SELECT revoked_at FROM revoked_tokens WHERE token_id = ?`,
+		tokenID,
+	).Scan(&revoked)
+	if err != nil {
+		if err == gocql.ErrNotFound {
+			return false, nil
+		}
+		logg.ErrorContext(s.logCtx(), "store", "Failed to look up revoked token", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// ttlSeconds converts ttl to a Cassandra TTL, which must be at least one second.
+func ttlSeconds(ttl time.Duration) int {
+	return max(int(ttl.Seconds()), 1)
+}
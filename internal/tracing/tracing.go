@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer all spans of the service come from.
+const instrumentationName = "example.com/cassandrafeed"
+
+// Init installs the W3C trace context propagator and, if endpoint is set, a
+// tracer provider exporting spans over OTLP/HTTP to endpoint, e.g.
+// "http://otel-collector:4318". Without an endpoint the global no-op provider
+// stays in place: spans are not recorded, but incoming trace context is
+// still passed on to Kafka messages.
+//
+// sampleRatio is the share of new traces recorded; traces started upstream
+// keep the caller's sampling decision. The returned function flushes
+// pending spans and must be called on shutdown.
+func Init(ctx context.Context, endpoint string, sampleRatio float64, service string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", service)))
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the tracer of the service.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject returns the trace context of ctx as a map, for storing it with data
+// that is published later, such as outbox events.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
+
+// Extract returns ctx carrying the trace context stored by Inject.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// InjectKafka writes the trace context of ctx into the headers of msg.
+func InjectKafka(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, kafkaCarrier{&msg.Headers})
+}
+
+// ExtractKafka returns ctx carrying the trace context found in the headers of msg.
+func ExtractKafka(ctx context.Context, msg kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, kafkaCarrier{&msg.Headers})
+}
+
+// StartKafka starts a producer or consumer span for msg, named after the
+// operation and the message key, which is the event type.
+func StartKafka(ctx context.Context, kind trace.SpanKind, operation string, msg kafka.Message) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{
+		attribute.String("messaging.system", "kafka"),
+		attribute.String("messaging.operation.name", operation),
+		attribute.String("messaging.kafka.message.key", string(msg.Key)),
+	}
+	if msg.Topic != "" {
+		// Only known for fetched messages; writers are bound to their topic.
+		attrs = append(attrs,
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.destination.partition.id", msg.Partition),
+			attribute.Int64("messaging.kafka.offset", msg.Offset),
+		)
+	}
+	return Tracer().Start(ctx, operation+" "+string(msg.Key),
+		trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// RecordCassandra records a finished Cassandra query or batch as a child of
+// the span in ctx. Queries outside a trace are not recorded, so background
+// polling does not flood the exporter with single-span traces.
+func RecordCassandra(ctx context.Context, op, table string, start, end time.Time, err error) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+	name := op
+	if table != "" {
+		name += " " + table
+	}
+	_, span := Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "cassandra"),
+			attribute.String("db.operation.name", op),
+			attribute.String("db.collection.name", table),
+		),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// kafkaCarrier adapts Kafka message headers to a propagation.TextMapCarrier.
+type kafkaCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c kafkaCarrier) Get(key string) string {
+	for _, h := range *c.headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c kafkaCarrier) Set(key, value string) {
+	for i, h := range *c.headers {
+		if h.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c kafkaCarrier) Keys() []string {
+	keys := make([]string, 0, len(*c.headers))
+	for _, h := range *c.headers {
+		keys = append(keys, h.Key)
+	}
+	return keys
+}
@@ -0,0 +1,99 @@
+// Package ttlcache provides a bounded in-memory map whose entries expire.
+package ttlcache
+
+import (
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a full cache drops its expired entries at most.
+const sweepInterval = time.Minute
+
+// Cache maps keys to values until their expiry. Once it holds maxEntries,
+// expired entries are swept at most every sweepInterval; if none are, an
+// arbitrary entry makes room, so new keys are never refused. A nil Cache
+// caches nothing.
+type Cache[K comparable, V any] struct {
+	maxEntries int
+
+	mu        sync.Mutex
+	entries   map[K]entry[V]
+	lastSweep time.Time
+}
+
+type entry[V any] struct {
+	value V
+	until time.Time
+}
+
+// New creates an empty cache of at most maxEntries entries.
+func New[K comparable, V any](maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{maxEntries: maxEntries, entries: make(map[K]entry[V])}
+}
+
+// Get returns the value of key, unless it is missing or expired.
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	var zero V
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.until) {
+		return zero, false
+	}
+	return e.value, true
+}
+
+// Set stores value for key until the given time, replacing any entry of key.
+func (c *Cache[K, V]) Set(key K, value V, until time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[key] = entry[V]{value: value, until: until}
+}
+
+// Delete drops the entry of key.
+func (c *Cache[K, V]) Delete(key K) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Len returns the number of entries, including expired ones not yet swept.
+func (c *Cache[K, V]) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// evict makes room for one entry.
+func (c *Cache[K, V]) evict() {
+	if now := time.Now(); now.Sub(c.lastSweep) >= sweepInterval {
+		c.lastSweep = now
+		for key, e := range c.entries {
+			if now.After(e.until) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) < c.maxEntries {
+			return
+		}
+	}
+	for key := range c.entries {
+		delete(c.entries, key)
+		return
+	}
+}
@@ -0,0 +1,68 @@
+package ttlcache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCache_Expiry(t *testing.T) {
+	c := New[string, int](10)
+	c.Set("live", 1, time.Now().Add(time.Hour))
+	c.Set("expired", 2, time.Now().Add(-time.Second))
+
+	if v, ok := c.Get("live"); !ok || v != 1 {
+		t.Fatalf("Get(live) = %d, %v, want 1, true", v, ok)
+	}
+	if _, ok := c.Get("expired"); ok {
+		t.Fatal("expected an expired entry to be missing")
+	}
+	c.Delete("live")
+	if _, ok := c.Get("live"); ok {
+		t.Fatal("expected a deleted entry to be missing")
+	}
+}
+
+func TestCache_FullSweepsExpired(t *testing.T) {
+	c := New[int, int](3)
+	c.Set(1, 1, time.Now().Add(-time.Second))
+	c.Set(2, 2, time.Now().Add(time.Hour))
+	c.Set(3, 3, time.Now().Add(time.Hour))
+
+	c.Set(4, 4, time.Now().Add(time.Hour))
+	if c.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", c.Len())
+	}
+	for _, key := range []int{2, 3, 4} {
+		if _, ok := c.Get(key); !ok {
+			t.Fatalf("expected live entry %d to be kept", key)
+		}
+	}
+}
+
+func TestCache_FullNeverRefuses(t *testing.T) {
+	c := New[int, string](2)
+	until := time.Now().Add(time.Hour)
+	c.Set(1, "a", until)
+	c.Set(2, "b", until)
+
+	// An existing key is overwritten without evicting anything
+	c.Set(1, "updated", until)
+	if v, _ := c.Get(1); v != "updated" || c.Len() != 2 {
+		t.Fatalf("expected key 1 to be overwritten, got %q with %d entries", v, c.Len())
+	}
+
+	// A new key replaces a live entry
+	c.Set(3, "c", until)
+	if v, ok := c.Get(3); !ok || v != "c" || c.Len() != 2 {
+		t.Fatalf("expected key 3 to be stored, got %q, %v with %d entries", v, ok, c.Len())
+	}
+}
+
+func TestCache_Nil(t *testing.T) {
+	var c *Cache[string, int]
+	c.Set("key", 1, time.Now().Add(time.Hour))
+	c.Delete("key")
+	if _, ok := c.Get("key"); ok || c.Len() != 0 {
+		t.Fatal("expected a nil cache to cache nothing")
+	}
+}
@@ -5,12 +5,19 @@ import (
 	"log"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"example.com/cassandrafeed/cmd/deadletter"
+	"example.com/cassandrafeed/cmd/relay"
 	"example.com/cassandrafeed/cmd/server"
 	"example.com/cassandrafeed/cmd/worker"
 	appkafka "example.com/cassandrafeed/internal/broker"
+	"example.com/cassandrafeed/internal/health"
 	config "example.com/cassandrafeed/internal/init"
+	"example.com/cassandrafeed/internal/jwtkeys"
+	"example.com/cassandrafeed/internal/logger"
 	"example.com/cassandrafeed/internal/store"
+	"example.com/cassandrafeed/internal/tracing"
 )
 
 func main() {
@@ -18,6 +25,11 @@ func main() {
 	cfg := config.Init()
 	mode := cfg.Mode
 
+	// Apply the log level to all loggers before anything is logged
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Fatalf("Invalid LOG_LEVEL %q: %v", cfg.LogLevel, err)
+	}
+
 	// Initialize Cassandra store connection
 	st, err := store.New()
 	if err != nil {
@@ -35,35 +47,142 @@ func main() {
 		ReadTimeout:  cfg.KafkaReadTO,
 	}
 
+	// Same Kafka settings bound to the retry and dead-letter topics
+	retryCfg := kafkaCfg
+	retryCfg.Topic = cfg.KafkaRetryTopic
+	dlqCfg := kafkaCfg
+	dlqCfg.Topic = cfg.KafkaDLQTopic
+
 	var kafkaWriter appkafka.KafkaWriter
 	var kafkaReader appkafka.KafkaReader
+	var retryPolicy worker.RetryPolicy
 
-	// Initialize Kafka writer for server mode
-	if mode == "server" {
+	switch mode {
+	case "relay", "deadletter":
+		// Initialize Kafka writer to the main topic for relay and replay modes
 		kafkaWriter, err = appkafka.NewKafkaWriter(kafkaCfg)
 		if err != nil {
 			log.Fatalf("Kafka writer init failed: %v", err)
 		}
 		defer kafkaWriter.Close()
-	} else {
+
+		if mode == "deadletter" {
+			// Replay consumes the DLQ with its own consumer group
+			dlqCfg.GroupID = cfg.KafkaGroupID + "-dlq-replay"
+			kafkaReader = appkafka.NewKafkaReader(dlqCfg)
+			defer kafkaReader.Close()
+		}
+	case "worker":
 		// Initialize Kafka reader for worker mode
 		kafkaReader = appkafka.NewKafkaReader(kafkaCfg)
 		defer kafkaReader.Close()
+
+		// Initialize retry and dead-letter pipeline
+		retryWriter, err := appkafka.NewKafkaWriter(retryCfg)
+		if err != nil {
+			log.Fatalf("Kafka retry writer init failed: %v", err)
+		}
+		defer retryWriter.Close()
+
+		dlqWriter, err := appkafka.NewKafkaWriter(dlqCfg)
+		if err != nil {
+			log.Fatalf("Kafka dead-letter writer init failed: %v", err)
+		}
+		defer dlqWriter.Close()
+
+		retryReader := appkafka.NewKafkaReader(retryCfg)
+		defer retryReader.Close()
+
+		retryPolicy = worker.RetryPolicy{
+			MaxAttempts: cfg.WorkerMaxAttempts,
+			Backoff:     cfg.WorkerRetryBackoff,
+			RetryReader: retryReader,
+			RetryWriter: retryWriter,
+			DLQWriter:   dlqWriter,
+		}
+	}
+
+	// Readiness check of the Kafka broker
+	pingKafka := func(ctx context.Context) error {
+		return appkafka.Ping(ctx, kafkaCfg.Brokers)
 	}
 
 	// Setup OS signal handling for graceful shutdown (SIGINT, SIGTERM)
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Initialize tracing; spans are only exported if an OTLP endpoint is set
+	shutdownTracing, err := tracing.Init(ctx, cfg.OTLPEndpoint, cfg.TraceSampleRatio, "cassandrafeed-"+mode)
+	if err != nil {
+		log.Fatalf("Tracing init failed: %v", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Printf("Flushing traces failed: %v", err)
+		}
+	}()
+
 	// Run application depending on selected mode
 	switch mode {
 	case "server":
-		// Start the server that writes posts to Kafka
-		server.Run(ctx, st, kafkaWriter, cfg.ServerAddr)
+		// Load the keys that sign and verify access tokens
+		var keys *jwtkeys.KeySet
+		if cfg.JWTKeysDir != "" {
+			keys, err = jwtkeys.LoadDir(cfg.JWTKeysDir, cfg.JWTSigningKeyID)
+		} else {
+			keys, err = jwtkeys.NewHMAC([]byte(cfg.JWTSecret))
+		}
+		if err != nil {
+			log.Fatalf("JWT key loading failed (set JWT_KEYS_DIR or JWT_SECRET): %v", err)
+		}
+
+		// Start the server that stores posts and their outbox events
+		server.Run(ctx, st, keys, cfg)
+	case "relay":
+		// Expose probes and Kafka write metrics on their own listener
+		checker := health.New()
+		checker.Add("cassandra", st.Ping)
+		checker.Add("kafka", pingKafka)
+		checker.ShutdownOn(ctx)
+		go health.Serve(cfg.MetricsAddr, checker)
+
+		// Start the relay that publishes outbox events to Kafka
+		r := relay.New(st, kafkaWriter, cfg.OutboxPollInterval, cfg.OutboxBatchSize)
+		r.Run(ctx)
 	case "worker":
 		// Start the worker that reads posts from Kafka and processes them
-		w := worker.New(st, kafkaReader, 0, 0)
+		w := worker.New(st, kafkaReader, 0, 0).
+			WithStallTimeout(cfg.WorkerStallTimeout).
+			WithRetryPolicy(retryPolicy).
+			WithFanoutPolicy(worker.FanoutPolicy{
+				Concurrency: cfg.FanoutConcurrency,
+				BatchSize:   cfg.FanoutBatchSize,
+			}).
+			WithLedger(worker.NewLedger(st, cfg.ProcessedEventTTL, cfg.ProcessedEventCacheSize))
+
+		// Expose probes, consumer and fan-out metrics on their own listener
+		checker := health.New()
+		checker.Add("cassandra", st.Ping)
+		checker.Add("kafka", pingKafka)
+		checker.Add("progress", w.CheckProgress)
+		checker.ShutdownOn(ctx)
+		go health.Serve(cfg.MetricsAddr, checker)
+
 		w.Run(ctx)
+	case "deadletter":
+		// Re-inject dead-lettered messages into the main topic, then exit
+		n, err := deadletter.Replay(ctx, kafkaReader, kafkaWriter, cfg.DLQReplayIdleTimeout)
+		if err != nil {
+			log.Fatalf("Dead-letter replay failed after %d messages: %v", n, err)
+		}
+	case "feed-backfill":
+		// Copy feeds from the legacy feed table into the current one, then exit
+		n, err := store.BackfillFeeds(ctx, st)
+		if err != nil {
+			log.Fatalf("Feed backfill failed after %d rows: %v", n, err)
+		}
 	default:
 		log.Fatalf("unknown mode: %s", mode)
 	}